@@ -2,14 +2,14 @@ package main
 
 import (
 	"context"
-	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/config"
 	"crypto-portfolio-tracker/internal/handlers"
+	"crypto-portfolio-tracker/internal/models"
 	"crypto-portfolio-tracker/internal/services"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,34 +17,46 @@ import (
 )
 
 func main() {
-	log.Println("🚀 Starting Crypto Portfolio Tracker API...")
-
-	// Initialize ScyllaDB
-	scyllaDB, err := db.NewScyllaDB([]string{"localhost:9042"})
-	if err != nil {
-		log.Fatalf("Failed to connect to ScyllaDB: %v", err)
+	// Subcommands run a one-off task instead of the API server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tax-report":
+			if err := runTaxReport(os.Args[2:]); err != nil {
+				log.Fatalf("Tax report failed: %v", err)
+			}
+			return
+		case "create-user":
+			if err := runCreateUser(os.Args[2:]); err != nil {
+				log.Fatalf("Creating user failed: %v", err)
+			}
+			return
+		case "migrate":
+			if err := runMigrate(os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		}
 	}
-	defer scyllaDB.Close()
 
-	// Initialize schema
-	if err := scyllaDB.InitSchema(); err != nil {
-		log.Fatalf("Failed to initialize ScyllaDB schema: %v", err)
-	}
+	log.Println("🚀 Starting Crypto Portfolio Tracker API...")
 
-	// Initialize ElasticSearch
-	elasticSearch, err := db.NewElasticSearch([]string{"http://localhost:9200"})
+	// Load configuration
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to connect to ElasticSearch: %v", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize ElasticSearch index
-	if err := elasticSearch.InitIndex(); err != nil {
-		log.Fatalf("Failed to initialize ElasticSearch index: %v", err)
+	// Initialize storage
+	stores, closeStores, err := openStores(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
+	defer closeStores()
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Crypto Portfolio Tracker API v1.0",
+		AppName:      "Crypto Portfolio Tracker API v1.0",
+		ErrorHandler: handlers.ErrorHandler,
 	})
 
 	// Middleware
@@ -52,24 +64,100 @@ func main() {
 	app.Use(cors.New())
 
 	// Initialize handlers
-	h := handlers.NewHandler(scyllaDB, elasticSearch)
+	provider, err := services.NewPriceProvider(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize price provider: %v", err)
+	}
+	backfiller := services.NewBackfiller(stores, provider, cfg.Backfill)
+	webhooks := services.NewWebhookDispatcher(stores, cfg.Webhooks)
+	stream := services.NewStreamHub()
+	auth, err := services.NewAuthenticator(stores, cfg.Auth)
+	if err != nil {
+		log.Fatalf("Failed to initialize authenticator: %v", err)
+	}
+	limiter, err := services.NewQuotaLimiter(cfg.RateLimit)
+	if err != nil {
+		log.Fatalf("Failed to initialize rate limiter: %v", err)
+	}
+	h := handlers.NewHandler(stores, provider, backfiller, webhooks, stream, auth, limiter)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	worker := services.NewPriceWorker(scyllaDB, elasticSearch, 1*time.Minute)
+	rates := services.NewExchangeRateSource(provider, cfg.CoinGecko)
+	worker := services.NewPriceWorker(stores, provider, rates, webhooks, stream, cfg)
 	go worker.Start(ctx)
+	go webhooks.Start(ctx)
 
 	// Routes
-	api := app.Group("/api/v1")
+	// Callers are identified by an X-API-Key header or a bearer access token
+	api := app.Group("/api/v1", h.Authenticate)
+
+	// Every client has a request budget per route class
+	read := h.RateLimit(services.ClassRead)
+	heavy := h.RateLimit(services.ClassHeavy)
+	admin := h.RateLimit(services.ClassAdmin)
+	authLimit := h.RateLimit(services.ClassAuth)
 
 	api.Get("/health", h.HealthCheck)
-	api.Post("/tokens", h.AddToken)
-	api.Get("/tokens/:id", h.GetToken)
-	api.Get("/search", h.SearchTokens)
-	api.Post("/sync", h.SyncTokens)
-	api.Get("/history/:id", h.GetPriceHistory)
-	api.Get("/tokens", h.GetAllTokens)
-	api.Get("/analytics", h.GetAnalytics)
+	api.Post("/auth/register", authLimit, h.Register)
+	api.Post("/auth/login", authLimit, h.Login)
+	api.Post("/auth/refresh", authLimit, h.RefreshToken)
+	api.Get("/auth/me", read, h.RequireAuth, h.Me)
+
+	// Shared market data is read by anyone and written by admins only
+	market := h.RequireScope(models.ScopeReadMarket)
+	api.Post("/tokens", admin, h.RequireAuth, h.RequireAdmin, h.AddToken)
+	api.Get("/tokens/:id", read, market, h.GetToken)
+	api.Get("/search", read, market, h.SearchTokens)
+	api.Post("/sync", admin, h.RequireAuth, h.RequireAdmin, h.SyncTokens)
+	api.Get("/history/:id", read, market, h.GetPriceHistory)
+	api.Get("/history/:id/candles", read, market, h.GetCandles)
+	api.Post("/history/:id/backfill", admin, h.RequireAuth, h.RequireAdmin, h.BackfillPriceHistory)
+	api.Get("/history/:id/backfill", read, market, h.GetBackfill)
+	api.Get("/tokens", heavy, market, h.GetAllTokens)
+	api.Get("/analytics", heavy, market, h.GetAnalytics)
+	api.Get("/fx/rates", read, market, h.GetExchangeRates)
+	api.Get("/stream", heavy, h.StreamPrices)
+
+	// API keys are managed by their user from a logged in session
+	keys := api.Group("/keys/:user", h.RateLimitByMethod, h.RequireAuth, h.RequireSelf, h.RequireSession)
+	keys.Get("/", h.GetAPIKeys)
+	keys.Post("/", h.CreateAPIKey)
+	keys.Get("/:id", h.GetAPIKey)
+	keys.Delete("/:id", h.RevokeAPIKey)
+
+	// Per-user routes are limited to their user and admins
+	portfolios := api.Group("/portfolios/:user", h.RateLimitByMethod, h.RequireAuth, h.RequireSelf, h.RequirePortfolioScope)
+	portfolios.Get("/holdings", h.GetHoldings)
+	portfolios.Post("/holdings", h.AddHolding)
+	portfolios.Get("/holdings/:token", h.GetHolding)
+	portfolios.Put("/holdings/:token", h.UpdateHolding)
+	portfolios.Delete("/holdings/:token", h.DeleteHolding)
+	portfolios.Get("/valuation", h.GetPortfolioValuation)
+	portfolios.Get("/history", h.GetPortfolioHistory)
+	portfolios.Get("/transactions", h.GetTransactions)
+	portfolios.Post("/transactions", h.AddTransaction)
+	portfolios.Delete("/transactions/:id", h.DeleteTransaction)
+	portfolios.Get("/positions", h.GetPositions)
+	portfolios.Get("/realized", h.GetRealizedGains)
+	portfolios.Get("/tax/:year", h.GetTaxReport)
+
+	alerts := api.Group("/alerts/:user", h.RateLimitByMethod, h.RequireAuth, h.RequireSelf, h.RequirePortfolioScope)
+	alerts.Get("/", h.GetAlerts)
+	alerts.Post("/", h.CreateAlert)
+	alerts.Get("/history", h.GetAlertHistory)
+	alerts.Get("/:id", h.GetAlert)
+	alerts.Put("/:id", h.UpdateAlert)
+	alerts.Delete("/:id", h.DeleteAlert)
+
+	webhookRoutes := api.Group("/webhooks/:user", h.RateLimitByMethod, h.RequireAuth, h.RequireSelf, h.RequirePortfolioScope)
+	webhookRoutes.Get("/", h.GetWebhooks)
+	webhookRoutes.Post("/", h.CreateWebhook)
+	webhookRoutes.Get("/deliveries", h.GetDeliveries)
+	webhookRoutes.Get("/deliveries/:id", h.GetDelivery)
+	webhookRoutes.Post("/deliveries/:id/redeliver", h.Redeliver)
+	webhookRoutes.Get("/:id", h.GetWebhook)
+	webhookRoutes.Delete("/:id", h.DeleteWebhook)
 
 	// Graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -78,21 +166,61 @@ func main() {
 	go func() {
 		<-c
 		log.Println("\n🛑 Shutting down gracefully...")
+		stream.Close()
 		app.Shutdown()
 	}()
 
 	// Start server
-	port := ":8080"
+	port := cfg.Server.Addr()
 	log.Printf("✅ Server running on http://localhost%s", port)
 	log.Println("📚 API Endpoints:")
 	log.Println("   GET  /api/v1/health")
-	log.Println("   POST /api/v1/tokens")
+	log.Println("   POST /api/v1/auth/register")
+	log.Println("   POST /api/v1/auth/login")
+	log.Println("   POST /api/v1/auth/refresh")
+	log.Println("   GET  /api/v1/auth/me")
+	log.Println("   GET  /api/v1/keys/:user")
+	log.Println("   POST /api/v1/keys/:user")
+	log.Println("   GET  /api/v1/keys/:user/:id")
+	log.Println("   DEL  /api/v1/keys/:user/:id")
+	log.Println("   POST /api/v1/tokens (admin)")
 	log.Println("   GET  /api/v1/tokens/:id")
 	log.Println("   GET  /api/v1/search?q=bitcoin")
-	log.Println("   POST /api/v1/sync?limit=10")
-	log.Println("   GET  /api/v1/history/:id?limit=100")
+	log.Println("   POST /api/v1/sync?limit=10 (admin)")
+	log.Println("   GET  /api/v1/history/:id?limit=100&from=&to=&cursor=")
+	log.Println("   GET  /api/v1/history/:id/candles?interval=1h&from=&to=")
+	log.Println("   POST /api/v1/history/:id/backfill?days=30 (admin)")
+	log.Println("   GET  /api/v1/history/:id/backfill")
 	log.Println("   GET  /api/v1/analytics")
-	log.Println("   GET  /api/v1/tokens")
+	log.Println("   GET  /api/v1/tokens?currency=eur")
+	log.Println("   GET  /api/v1/fx/rates")
+	log.Println("   GET  /api/v1/stream?tokens=bitcoin,ethereum (WebSocket or SSE)")
+	log.Println("   GET  /api/v1/stream?portfolio=:user (WebSocket or SSE)")
+	log.Println("   GET  /api/v1/portfolios/:user/holdings")
+	log.Println("   POST /api/v1/portfolios/:user/holdings")
+	log.Println("   PUT  /api/v1/portfolios/:user/holdings/:token")
+	log.Println("   DEL  /api/v1/portfolios/:user/holdings/:token")
+	log.Println("   GET  /api/v1/portfolios/:user/valuation")
+	log.Println("   GET  /api/v1/portfolios/:user/history?from=&to=&step=1d")
+	log.Println("   GET  /api/v1/portfolios/:user/transactions")
+	log.Println("   POST /api/v1/portfolios/:user/transactions")
+	log.Println("   DEL  /api/v1/portfolios/:user/transactions/:id")
+	log.Println("   GET  /api/v1/portfolios/:user/positions")
+	log.Println("   GET  /api/v1/portfolios/:user/realized?method=fifo")
+	log.Println("   GET  /api/v1/portfolios/:user/tax/:year?format=csv")
+	log.Println("   GET  /api/v1/alerts/:user")
+	log.Println("   POST /api/v1/alerts/:user")
+	log.Println("   GET  /api/v1/alerts/:user/history?limit=100")
+	log.Println("   GET  /api/v1/alerts/:user/:id")
+	log.Println("   PUT  /api/v1/alerts/:user/:id")
+	log.Println("   DEL  /api/v1/alerts/:user/:id")
+	log.Println("   GET  /api/v1/webhooks/:user")
+	log.Println("   POST /api/v1/webhooks/:user")
+	log.Println("   GET  /api/v1/webhooks/:user/deliveries?limit=100")
+	log.Println("   GET  /api/v1/webhooks/:user/deliveries/:id")
+	log.Println("   POST /api/v1/webhooks/:user/deliveries/:id/redeliver")
+	log.Println("   GET  /api/v1/webhooks/:user/:id")
+	log.Println("   DEL  /api/v1/webhooks/:user/:id")
 
 	if err := app.Listen(port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
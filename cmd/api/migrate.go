@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runMigrate implements the `migrate up|down|status` subcommand
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status [flags]")
+	}
+	command := args[0]
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	store := fs.String("store", "all", "store to migrate: all, scylla or elasticsearch")
+	to := fs.Int("to", 0, "version to migrate up to (default latest)")
+	steps := fs.Int("steps", 1, "versions to roll back")
+	fs.Parse(args[1:])
+
+	if command != "up" && command != "down" && command != "status" {
+		return fmt.Errorf("unknown migrate command '%s', expected up, down or status", command)
+	}
+	if *store != "all" && *store != "scylla" && *store != "elasticsearch" {
+		return fmt.Errorf("flag -store must be all, scylla or elasticsearch")
+	}
+	if *steps < 1 {
+		return fmt.Errorf("flag -steps must be at least 1")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.Driver == "memory" {
+		return fmt.Errorf("migrations only apply to the scylla storage driver")
+	}
+
+	ctx := context.Background()
+
+	if *store == "all" || *store == "scylla" {
+		if err := migrateScylla(ctx, cfg, command, *to, *steps); err != nil {
+			return err
+		}
+	}
+	if *store == "all" || *store == "elasticsearch" {
+		if err := migrateElasticSearch(ctx, cfg, command, *to, *steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateScylla(ctx context.Context, cfg *config.Config, command string, to, steps int) error {
+	scyllaDB, err := db.NewScyllaDB(cfg.Scylla, cfg.Retention)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ScyllaDB: %w", err)
+	}
+	defer scyllaDB.Close()
+
+	if err := scyllaDB.InitKeyspace(); err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		if to == 0 {
+			to = db.LatestScyllaVersion()
+		}
+		return scyllaDB.Migrate(ctx, to)
+
+	case "down":
+		current, err := scyllaDB.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		return scyllaDB.Migrate(ctx, max(current-steps, 0))
+	}
+
+	migrations, err := scyllaDB.Migrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tAPPLIED")
+	for _, m := range migrations {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	return w.Flush()
+}
+
+// Index versions can only be rolled back to 1, as an index always exists
+func migrateElasticSearch(ctx context.Context, cfg *config.Config, command string, to, steps int) error {
+	elasticSearch, err := db.NewElasticSearch(cfg.ElasticSearch)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ElasticSearch: %w", err)
+	}
+
+	switch command {
+	case "up":
+		if to == 0 {
+			to = db.LatestIndexVersion()
+		}
+		return elasticSearch.MigrateIndex(ctx, to)
+
+	case "down":
+		current, err := elasticSearch.IndexVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return fmt.Errorf("index '%s' is not versioned yet, run migrate up first", cfg.ElasticSearch.Index)
+		}
+		return elasticSearch.MigrateIndex(ctx, max(current-steps, 1))
+	}
+
+	current, err := elasticSearch.IndexVersion(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nElasticSearch index '%s': version %d of %d\n", cfg.ElasticSearch.Index, current, db.LatestIndexVersion())
+	return nil
+}
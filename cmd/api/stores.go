@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"fmt"
+	"log"
+)
+
+// openStores connects the storage backend selected by the configuration.
+// The returned function releases its connections.
+func openStores(cfg *config.Config) (db.Stores, func(), error) {
+	if cfg.Storage.Driver == "memory" {
+		log.Println("⚠️  Using in-memory storage, data is lost on shutdown")
+		return db.NewMemoryStores(), func() {}, nil
+	}
+
+	// Initialize ScyllaDB
+	scyllaDB, err := db.NewScyllaDB(cfg.Scylla, cfg.Retention)
+	if err != nil {
+		return db.Stores{}, nil, fmt.Errorf("failed to connect to ScyllaDB: %w", err)
+	}
+
+	// Initialize schema
+	if err := scyllaDB.InitSchema(); err != nil {
+		scyllaDB.Close()
+		return db.Stores{}, nil, fmt.Errorf("failed to initialize ScyllaDB schema: %w", err)
+	}
+
+	// Initialize ElasticSearch
+	elasticSearch, err := db.NewElasticSearch(cfg.ElasticSearch)
+	if err != nil {
+		scyllaDB.Close()
+		return db.Stores{}, nil, fmt.Errorf("failed to connect to ElasticSearch: %w", err)
+	}
+
+	// Initialize ElasticSearch index
+	if err := elasticSearch.InitIndex(); err != nil {
+		scyllaDB.Close()
+		return db.Stores{}, nil, fmt.Errorf("failed to initialize ElasticSearch index: %w", err)
+	}
+
+	return db.NewClusterStores(scyllaDB, elasticSearch), scyllaDB.Close, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/costbasis"
+	"crypto-portfolio-tracker/internal/services"
+	"crypto-portfolio-tracker/internal/tax"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runTaxReport implements the `tax-report` subcommand
+func runTaxReport(args []string) error {
+	fs := flag.NewFlagSet("tax-report", flag.ExitOnError)
+	userID := fs.String("user", "", "user whose ledger is reported (required)")
+	year := fs.Int("year", time.Now().Year()-1, "calendar year to report")
+	methodName := fs.String("method", string(costbasis.FIFO), "cost basis method: fifo, lifo, hifo or average")
+	format := fs.String("format", "csv", "output format: csv or json")
+	output := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *userID == "" {
+		return fmt.Errorf("flag -user is required")
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("flag -format must be csv or json")
+	}
+	method, err := costbasis.ParseMethod(*methodName)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	stores, closeStores, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	report, err := services.BuildTaxReport(context.Background(), stores, *userID, *year, method)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return tax.WriteCSV(w, report)
+}
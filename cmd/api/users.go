@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// runCreateUser implements the `create-user` subcommand, which is how the
+// first admin is created. The password is read from stdin so it stays out
+// of the shell history.
+func runCreateUser(args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ExitOnError)
+	username := fs.String("username", "", "username of the account (required)")
+	role := fs.String("role", string(models.RoleAdmin), "role of the account: user or admin")
+	fs.Parse(args)
+
+	if *username == "" {
+		return fmt.Errorf("flag -username is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.Driver == "memory" {
+		return fmt.Errorf("users created with the memory storage driver would be lost on exit")
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	stores, closeStores, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	auth, err := services.NewAuthenticator(stores, cfg.Auth)
+	if err != nil {
+		return err
+	}
+	user, err := auth.CreateUser(context.Background(), *username, password, models.Role(*role))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Created %s user %s", user.Role, user.Username)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package apierror defines the errors the HTTP API returns to clients. Every
+// failure is written as {"error": message, "code": code} plus, for invalid
+// requests, "details" naming each offending field.
+package apierror
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code identifies a kind of failure that clients can program against
+type Code string
+
+const (
+	CodeInvalidRequest    Code = "invalid_request"
+	CodeValidationFailed  Code = "validation_failed"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeInsufficientScope Code = "insufficient_scope"
+	CodeNotFound          Code = "not_found"
+	CodeMethodNotAllowed  Code = "method_not_allowed"
+	CodeConflict          Code = "conflict"
+	CodeUnprocessable     Code = "unprocessable"
+	CodeRateLimited       Code = "rate_limited"
+	CodeInternal          Code = "internal_error"
+	CodeUpstream          Code = "upstream_error"
+)
+
+// FieldError describes one invalid field of a request body or query
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a failure with the HTTP status it is returned with
+type Error struct {
+	Status  int          `json:"-"`
+	Message string       `json:"error"`
+	Code    Code         `json:"code"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Details) == 0 {
+		return e.Message
+	}
+
+	fields := make([]string, len(e.Details))
+	for i, detail := range e.Details {
+		fields[i] = detail.Field + " " + detail.Message
+	}
+	return e.Message + ": " + strings.Join(fields, "; ")
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(400, CodeInvalidRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(401, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(403, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(404, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(409, CodeConflict, message)
+}
+
+func Unprocessable(message string) *Error {
+	return New(422, CodeUnprocessable, message)
+}
+
+func RateLimited(message string) *Error {
+	return New(429, CodeRateLimited, message)
+}
+
+func Internal(message string) *Error {
+	return New(500, CodeInternal, message)
+}
+
+func Upstream(message string) *Error {
+	return New(502, CodeUpstream, message)
+}
+
+// Validation reports invalid fields of a request
+func Validation(details ...FieldError) *Error {
+	return &Error{Status: 400, Code: CodeValidationFailed, Message: "Request validation failed", Details: details}
+}
+
+// Field reports a single invalid field
+func Field(field, format string, args ...interface{}) *Error {
+	return Validation(FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validator collects the invalid fields of a request, so clients learn about
+// all of them at once
+type Validator struct {
+	details []FieldError
+}
+
+// Check records a field error unless ok holds
+func (v *Validator) Check(ok bool, field, format string, args ...interface{}) {
+	if !ok {
+		v.details = append(v.details, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Err returns the collected field errors, or nil when there are none
+func (v *Validator) Err() error {
+	if len(v.details) == 0 {
+		return nil
+	}
+	return Validation(v.details...)
+}
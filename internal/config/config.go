@@ -0,0 +1,647 @@
+// Package config loads the application settings from defaults, an optional
+// YAML file, a .env file and environment variables, in that order of precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when CONFIG_FILE is not set and the file exists
+const DefaultConfigFile = "config.yaml"
+
+// MaxTopTokens caps how many tokens a single sync may request
+const MaxTopTokens = 5000
+
+type Config struct {
+	Server        ServerConfig    `yaml:"server"`
+	Storage       StorageConfig   `yaml:"storage"`
+	Scylla        ScyllaConfig    `yaml:"scylla"`
+	ElasticSearch ElasticConfig   `yaml:"elasticsearch"`
+	Worker        WorkerConfig    `yaml:"worker"`
+	Backfill      BackfillConfig  `yaml:"backfill"`
+	Retention     RetentionConfig `yaml:"retention"`
+	Webhooks      WebhookConfig   `yaml:"webhooks"`
+	Auth          AuthConfig      `yaml:"auth"`
+	RateLimit     RateLimitConfig `yaml:"rate_limit"`
+	Prices        PricesConfig    `yaml:"prices"`
+	CoinGecko     CoinGeckoConfig `yaml:"coingecko"`
+	Binance       ExchangeConfig  `yaml:"binance"`
+	Kraken        ExchangeConfig  `yaml:"kraken"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port"`
+}
+
+// StorageConfig selects the storage backend: "scylla" uses ScyllaDB and
+// ElasticSearch, "memory" keeps everything in process for tests and local dev
+type StorageConfig struct {
+	Driver string `yaml:"driver"`
+}
+
+type ScyllaConfig struct {
+	Hosts             []string      `yaml:"hosts"`
+	Keyspace          string        `yaml:"keyspace"`
+	ReplicationFactor int           `yaml:"replication_factor"`
+	Consistency       string        `yaml:"consistency"`
+	Timeout           time.Duration `yaml:"timeout"`
+}
+
+type ElasticConfig struct {
+	Addresses []string `yaml:"addresses"`
+	Index     string   `yaml:"index"`
+}
+
+// WorkerConfig controls the background price sync. Each cycle syncs the
+// TopTokens largest tokens plus every token ID listed in Watchlist.
+type WorkerConfig struct {
+	Interval  time.Duration `yaml:"interval"`
+	TopTokens int           `yaml:"top_tokens"`
+	Watchlist []string      `yaml:"watchlist"`
+}
+
+// BackfillConfig controls historical price backfills. Each backfill is fetched
+// in windows of Chunk, which is also the unit of resumption after a failure.
+type BackfillConfig struct {
+	Chunk   time.Duration `yaml:"chunk"`
+	MaxDays int           `yaml:"max_days"`
+}
+
+// RetentionConfig sets how long ScyllaDB keeps raw price ticks and each
+// candle interval before they expire. Zero keeps the rows forever.
+type RetentionConfig struct {
+	Raw       time.Duration `yaml:"raw"`
+	Candles1m time.Duration `yaml:"candles_1m"`
+	Candles5m time.Duration `yaml:"candles_5m"`
+	Candles1h time.Duration `yaml:"candles_1h"`
+	Candles1d time.Duration `yaml:"candles_1d"`
+}
+
+// CandleTTL returns the retention of a candle interval
+func (r RetentionConfig) CandleTTL(interval string) time.Duration {
+	switch interval {
+	case "1m":
+		return r.Candles1m
+	case "5m":
+		return r.Candles5m
+	case "1h":
+		return r.Candles1h
+	case "1d":
+		return r.Candles1d
+	}
+	return 0
+}
+
+// WebhookConfig controls webhook delivery. Deliveries are queued and sent by
+// up to Concurrency requests at a time; failed ones are retried up to
+// MaxRetries times with exponential backoff between the two delays. The
+// dispatcher looks for due deliveries every PollInterval.
+type WebhookConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxRetries     int           `yaml:"max_retries"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+	PollInterval   time.Duration `yaml:"poll_interval"`
+	Concurrency    int           `yaml:"concurrency"`
+}
+
+// AuthConfig controls user authentication. Access and refresh tokens are
+// JWTs signed with JWTSecret; without a secret a random one is generated at
+// startup, so tokens do not survive restarts.
+type AuthConfig struct {
+	JWTSecret  string        `yaml:"jwt_secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+// RateLimitConfig sizes the request budgets of the HTTP API. Every route class
+// allows its number of requests per Window to each client: an API key, a
+// logged in user, or an IP address for anonymous callers. Backend selects
+// where requests are counted; "none" disables rate limiting.
+type RateLimitConfig struct {
+	Backend string        `yaml:"backend"`
+	Window  time.Duration `yaml:"window"`
+	Read    int           `yaml:"read"`
+	Write   int           `yaml:"write"`
+	Heavy   int           `yaml:"heavy"`
+	Admin   int           `yaml:"admin"`
+	Auth    int           `yaml:"auth"`
+}
+
+// minJWTSecret is the shortest accepted HS256 secret
+const minJWTSecret = 32
+
+// maxTTL is the longest TTL ScyllaDB accepts (20 years)
+const maxTTL = 20 * 365 * 24 * time.Hour
+
+// PricesConfig selects the price providers and how their quotes are combined.
+// In "fallback" mode providers are tried in order until one succeeds; in
+// "aggregate" mode all are queried and a consensus price is computed, ignoring
+// quotes further than MaxDeviation (a fraction) from the median.
+// Symbols maps token IDs to exchange base assets for providers keyed by ticker.
+// Currencies lists the quote currencies stored next to each USD price.
+type PricesConfig struct {
+	Providers    []string          `yaml:"providers"`
+	Mode         string            `yaml:"mode"`
+	Consensus    string            `yaml:"consensus"`
+	MaxDeviation float64           `yaml:"max_deviation"`
+	MinSources   int               `yaml:"min_sources"`
+	Symbols      map[string]string `yaml:"symbols"`
+	Currencies   []string          `yaml:"currencies"`
+}
+
+// CoinGeckoConfig configures the CoinGecko client. RequestsPerMinute and Burst
+// size the token bucket shared by every request; failed requests are retried
+// up to MaxRetries times with exponential backoff between the two delays.
+type CoinGeckoConfig struct {
+	BaseURL           string        `yaml:"base_url"`
+	APIKey            string        `yaml:"api_key"`
+	Timeout           time.Duration `yaml:"timeout"`
+	RequestsPerMinute int           `yaml:"requests_per_minute"`
+	Burst             int           `yaml:"burst"`
+	MaxRetries        int           `yaml:"max_retries"`
+	RetryBaseDelay    time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay     time.Duration `yaml:"retry_max_delay"`
+}
+
+// ExchangeConfig configures an exchange REST adapter
+type ExchangeConfig struct {
+	BaseURL    string        `yaml:"base_url"`
+	QuoteAsset string        `yaml:"quote_asset"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// Providers known to the price service
+var knownProviders = map[string]bool{"coingecko": true, "binance": true, "kraken": true}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port: 8080,
+		},
+		Storage: StorageConfig{
+			Driver: "scylla",
+		},
+		Scylla: ScyllaConfig{
+			Hosts:             []string{"localhost:9042"},
+			Keyspace:          "crypto_tracker",
+			ReplicationFactor: 1,
+			Consistency:       "QUORUM",
+			Timeout:           10 * time.Second,
+		},
+		ElasticSearch: ElasticConfig{
+			Addresses: []string{"http://localhost:9200"},
+			Index:     "crypto_tokens",
+		},
+		Worker: WorkerConfig{
+			Interval:  1 * time.Minute,
+			TopTokens: 10,
+		},
+		Backfill: BackfillConfig{
+			Chunk:   30 * 24 * time.Hour,
+			MaxDays: 365,
+		},
+		Retention: RetentionConfig{
+			Raw:       90 * 24 * time.Hour,
+			Candles1m: 7 * 24 * time.Hour,
+			Candles5m: 30 * 24 * time.Hour,
+			Candles1h: 2 * 365 * 24 * time.Hour,
+			Candles1d: 0,
+		},
+		Webhooks: WebhookConfig{
+			Timeout:        10 * time.Second,
+			MaxRetries:     5,
+			RetryBaseDelay: 30 * time.Second,
+			RetryMaxDelay:  1 * time.Hour,
+			PollInterval:   10 * time.Second,
+			Concurrency:    8,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Backend: "memory",
+			Window:  time.Minute,
+			Read:    300,
+			Write:   60,
+			Heavy:   30,
+			Admin:   10,
+			Auth:    10,
+		},
+		Prices: PricesConfig{
+			Providers:    []string{"coingecko"},
+			Mode:         "fallback",
+			Consensus:    "median",
+			MaxDeviation: 0.02,
+			MinSources:   1,
+			Currencies:   []string{"usd", "eur", "gbp", "btc", "eth"},
+			Symbols: map[string]string{
+				"bitcoin":     "BTC",
+				"ethereum":    "ETH",
+				"tether":      "USDT",
+				"binancecoin": "BNB",
+				"solana":      "SOL",
+				"ripple":      "XRP",
+				"usd-coin":    "USDC",
+				"cardano":     "ADA",
+				"dogecoin":    "DOGE",
+				"tron":        "TRX",
+				"polkadot":    "DOT",
+				"chainlink":   "LINK",
+				"litecoin":    "LTC",
+				"avalanche-2": "AVAX",
+			},
+		},
+		CoinGecko: CoinGeckoConfig{
+			BaseURL:           "https://api.coingecko.com/api/v3",
+			Timeout:           10 * time.Second,
+			RequestsPerMinute: 25,
+			Burst:             5,
+			MaxRetries:        3,
+			RetryBaseDelay:    1 * time.Second,
+			RetryMaxDelay:     30 * time.Second,
+		},
+		Binance: ExchangeConfig{
+			BaseURL:    "https://api.binance.com",
+			QuoteAsset: "USDT",
+			Timeout:    10 * time.Second,
+		},
+		Kraken: ExchangeConfig{
+			BaseURL:    "https://api.kraken.com",
+			QuoteAsset: "USD",
+			Timeout:    10 * time.Second,
+		},
+	}
+}
+
+// Load builds the configuration and validates it.
+// The YAML file is taken from CONFIG_FILE, or config.yaml when present.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	required := path != ""
+	if path == "" {
+		path = DefaultConfigFile
+	}
+	if err := cfg.loadFile(path, required); err != nil {
+		return nil, err
+	}
+
+	// .env never overrides variables already set in the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	var errs []error
+
+	envInt("SERVER_PORT", &cfg.Server.Port, &errs)
+
+	envString("STORAGE_DRIVER", &cfg.Storage.Driver)
+
+	envList("SCYLLA_HOSTS", &cfg.Scylla.Hosts)
+	envString("SCYLLA_KEYSPACE", &cfg.Scylla.Keyspace)
+	envInt("SCYLLA_REPLICATION_FACTOR", &cfg.Scylla.ReplicationFactor, &errs)
+	envString("SCYLLA_CONSISTENCY", &cfg.Scylla.Consistency)
+	envDuration("SCYLLA_TIMEOUT", &cfg.Scylla.Timeout, &errs)
+
+	envList("ELASTICSEARCH_ADDRESSES", &cfg.ElasticSearch.Addresses)
+	envString("ELASTICSEARCH_INDEX", &cfg.ElasticSearch.Index)
+
+	envDuration("WORKER_INTERVAL", &cfg.Worker.Interval, &errs)
+	envInt("WORKER_TOP_TOKENS", &cfg.Worker.TopTokens, &errs)
+	envList("WORKER_WATCHLIST", &cfg.Worker.Watchlist)
+
+	envDuration("BACKFILL_CHUNK", &cfg.Backfill.Chunk, &errs)
+	envInt("BACKFILL_MAX_DAYS", &cfg.Backfill.MaxDays, &errs)
+
+	envDuration("RETENTION_RAW", &cfg.Retention.Raw, &errs)
+	envDuration("RETENTION_CANDLES_1M", &cfg.Retention.Candles1m, &errs)
+	envDuration("RETENTION_CANDLES_5M", &cfg.Retention.Candles5m, &errs)
+	envDuration("RETENTION_CANDLES_1H", &cfg.Retention.Candles1h, &errs)
+	envDuration("RETENTION_CANDLES_1D", &cfg.Retention.Candles1d, &errs)
+
+	envDuration("WEBHOOK_TIMEOUT", &cfg.Webhooks.Timeout, &errs)
+	envInt("WEBHOOK_MAX_RETRIES", &cfg.Webhooks.MaxRetries, &errs)
+	envDuration("WEBHOOK_RETRY_BASE_DELAY", &cfg.Webhooks.RetryBaseDelay, &errs)
+	envDuration("WEBHOOK_RETRY_MAX_DELAY", &cfg.Webhooks.RetryMaxDelay, &errs)
+	envDuration("WEBHOOK_POLL_INTERVAL", &cfg.Webhooks.PollInterval, &errs)
+	envInt("WEBHOOK_CONCURRENCY", &cfg.Webhooks.Concurrency, &errs)
+
+	envString("AUTH_JWT_SECRET", &cfg.Auth.JWTSecret)
+	envDuration("AUTH_ACCESS_TTL", &cfg.Auth.AccessTTL, &errs)
+	envDuration("AUTH_REFRESH_TTL", &cfg.Auth.RefreshTTL, &errs)
+
+	envString("RATE_LIMIT_BACKEND", &cfg.RateLimit.Backend)
+	envDuration("RATE_LIMIT_WINDOW", &cfg.RateLimit.Window, &errs)
+	envInt("RATE_LIMIT_READ", &cfg.RateLimit.Read, &errs)
+	envInt("RATE_LIMIT_WRITE", &cfg.RateLimit.Write, &errs)
+	envInt("RATE_LIMIT_HEAVY", &cfg.RateLimit.Heavy, &errs)
+	envInt("RATE_LIMIT_ADMIN", &cfg.RateLimit.Admin, &errs)
+	envInt("RATE_LIMIT_AUTH", &cfg.RateLimit.Auth, &errs)
+
+	envList("PRICE_PROVIDERS", &cfg.Prices.Providers)
+	envString("PRICE_MODE", &cfg.Prices.Mode)
+	envString("PRICE_CONSENSUS", &cfg.Prices.Consensus)
+	envFloat("PRICE_MAX_DEVIATION", &cfg.Prices.MaxDeviation, &errs)
+	envInt("PRICE_MIN_SOURCES", &cfg.Prices.MinSources, &errs)
+	envList("PRICE_CURRENCIES", &cfg.Prices.Currencies)
+
+	envString("COINGECKO_BASE_URL", &cfg.CoinGecko.BaseURL)
+	envString("COINGECKO_API_KEY", &cfg.CoinGecko.APIKey)
+	envDuration("COINGECKO_TIMEOUT", &cfg.CoinGecko.Timeout, &errs)
+	envInt("COINGECKO_REQUESTS_PER_MINUTE", &cfg.CoinGecko.RequestsPerMinute, &errs)
+	envInt("COINGECKO_BURST", &cfg.CoinGecko.Burst, &errs)
+	envInt("COINGECKO_MAX_RETRIES", &cfg.CoinGecko.MaxRetries, &errs)
+	envDuration("COINGECKO_RETRY_BASE_DELAY", &cfg.CoinGecko.RetryBaseDelay, &errs)
+	envDuration("COINGECKO_RETRY_MAX_DELAY", &cfg.CoinGecko.RetryMaxDelay, &errs)
+
+	envString("BINANCE_BASE_URL", &cfg.Binance.BaseURL)
+	envString("BINANCE_QUOTE_ASSET", &cfg.Binance.QuoteAsset)
+	envDuration("BINANCE_TIMEOUT", &cfg.Binance.Timeout, &errs)
+
+	envString("KRAKEN_BASE_URL", &cfg.Kraken.BaseURL)
+	envString("KRAKEN_QUOTE_ASSET", &cfg.Kraken.QuoteAsset)
+	envDuration("KRAKEN_TIMEOUT", &cfg.Kraken.Timeout, &errs)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535"))
+	}
+
+	if cfg.Storage.Driver != "scylla" && cfg.Storage.Driver != "memory" {
+		errs = append(errs, fmt.Errorf("storage.driver must be 'scylla' or 'memory'"))
+	}
+
+	if len(cfg.Scylla.Hosts) == 0 {
+		errs = append(errs, fmt.Errorf("scylla.hosts must not be empty"))
+	}
+	if cfg.Scylla.Keyspace == "" {
+		errs = append(errs, fmt.Errorf("scylla.keyspace must not be empty"))
+	}
+	if cfg.Scylla.ReplicationFactor < 1 {
+		errs = append(errs, fmt.Errorf("scylla.replication_factor must be at least 1"))
+	}
+	if _, err := ParseConsistency(cfg.Scylla.Consistency); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Scylla.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("scylla.timeout must be positive"))
+	}
+
+	if len(cfg.ElasticSearch.Addresses) == 0 {
+		errs = append(errs, fmt.Errorf("elasticsearch.addresses must not be empty"))
+	}
+	if cfg.ElasticSearch.Index == "" {
+		errs = append(errs, fmt.Errorf("elasticsearch.index must not be empty"))
+	}
+
+	if cfg.Worker.Interval < time.Second {
+		errs = append(errs, fmt.Errorf("worker.interval must be at least 1s"))
+	}
+	if cfg.Worker.TopTokens < 1 || cfg.Worker.TopTokens > MaxTopTokens {
+		errs = append(errs, fmt.Errorf("worker.top_tokens must be between 1 and %d", MaxTopTokens))
+	}
+
+	if cfg.Backfill.Chunk < time.Hour {
+		errs = append(errs, fmt.Errorf("backfill.chunk must be at least 1h"))
+	}
+	if cfg.Backfill.MaxDays < 1 {
+		errs = append(errs, fmt.Errorf("backfill.max_days must be at least 1"))
+	}
+
+	retention := []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"raw", cfg.Retention.Raw},
+		{"candles_1m", cfg.Retention.Candles1m},
+		{"candles_5m", cfg.Retention.Candles5m},
+		{"candles_1h", cfg.Retention.Candles1h},
+		{"candles_1d", cfg.Retention.Candles1d},
+	}
+	for _, r := range retention {
+		if r.ttl < 0 || r.ttl > maxTTL {
+			errs = append(errs, fmt.Errorf("retention.%s must be between 0 and 20 years", r.name))
+		}
+	}
+
+	if cfg.Webhooks.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout must be positive"))
+	}
+	if cfg.Webhooks.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("webhooks.max_retries must not be negative"))
+	}
+	if cfg.Webhooks.RetryBaseDelay <= 0 || cfg.Webhooks.RetryMaxDelay < cfg.Webhooks.RetryBaseDelay {
+		errs = append(errs, fmt.Errorf("webhooks.retry_base_delay must be positive and not exceed webhooks.retry_max_delay"))
+	}
+	if cfg.Webhooks.PollInterval < time.Second {
+		errs = append(errs, fmt.Errorf("webhooks.poll_interval must be at least 1s"))
+	}
+	if cfg.Webhooks.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.concurrency must be at least 1"))
+	}
+
+	if cfg.Auth.JWTSecret != "" && len(cfg.Auth.JWTSecret) < minJWTSecret {
+		errs = append(errs, fmt.Errorf("auth.jwt_secret must be at least %d characters", minJWTSecret))
+	}
+	if cfg.Auth.AccessTTL <= 0 || cfg.Auth.RefreshTTL < cfg.Auth.AccessTTL {
+		errs = append(errs, fmt.Errorf("auth.access_ttl must be positive and not exceed auth.refresh_ttl"))
+	}
+
+	switch cfg.RateLimit.Backend {
+	case "none":
+	case "memory":
+		if cfg.RateLimit.Window <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.window must be positive"))
+		}
+		if min(cfg.RateLimit.Read, cfg.RateLimit.Write, cfg.RateLimit.Heavy, cfg.RateLimit.Admin, cfg.RateLimit.Auth) < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.read, write, heavy, admin and auth must be at least 1"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.backend must be 'memory' or 'none', got '%s'", cfg.RateLimit.Backend))
+	}
+
+	if len(cfg.Prices.Providers) == 0 {
+		errs = append(errs, fmt.Errorf("prices.providers must not be empty"))
+	}
+	for _, name := range cfg.Prices.Providers {
+		if !knownProviders[name] {
+			errs = append(errs, fmt.Errorf("prices.providers: unknown provider '%s'", name))
+		}
+	}
+	if cfg.Prices.Mode != "fallback" && cfg.Prices.Mode != "aggregate" {
+		errs = append(errs, fmt.Errorf("prices.mode must be 'fallback' or 'aggregate'"))
+	}
+	if cfg.Prices.Consensus != "median" && cfg.Prices.Consensus != "vwap" {
+		errs = append(errs, fmt.Errorf("prices.consensus must be 'median' or 'vwap'"))
+	}
+	if cfg.Prices.MaxDeviation <= 0 || cfg.Prices.MaxDeviation >= 1 {
+		errs = append(errs, fmt.Errorf("prices.max_deviation must be between 0 and 1"))
+	}
+	if cfg.Prices.MinSources < 1 || cfg.Prices.MinSources > len(cfg.Prices.Providers) {
+		errs = append(errs, fmt.Errorf("prices.min_sources must be between 1 and the number of providers"))
+	}
+	for _, currency := range cfg.Prices.Currencies {
+		if currency == "" || currency != strings.ToLower(currency) {
+			errs = append(errs, fmt.Errorf("prices.currencies: '%s' must be a lowercase currency code", currency))
+		}
+	}
+
+	if cfg.CoinGecko.BaseURL == "" {
+		errs = append(errs, fmt.Errorf("coingecko.base_url must not be empty"))
+	}
+	if cfg.CoinGecko.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("coingecko.timeout must be positive"))
+	}
+	if cfg.CoinGecko.RequestsPerMinute < 1 || cfg.CoinGecko.Burst < 1 {
+		errs = append(errs, fmt.Errorf("coingecko.requests_per_minute and coingecko.burst must be at least 1"))
+	}
+	if cfg.CoinGecko.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("coingecko.max_retries must not be negative"))
+	}
+	if cfg.CoinGecko.RetryBaseDelay <= 0 || cfg.CoinGecko.RetryMaxDelay < cfg.CoinGecko.RetryBaseDelay {
+		errs = append(errs, fmt.Errorf("coingecko.retry_base_delay must be positive and not exceed coingecko.retry_max_delay"))
+	}
+
+	exchanges := []struct {
+		name string
+		cfg  ExchangeConfig
+	}{{"binance", cfg.Binance}, {"kraken", cfg.Kraken}}
+	for _, exchange := range exchanges {
+		if exchange.cfg.BaseURL == "" || exchange.cfg.QuoteAsset == "" {
+			errs = append(errs, fmt.Errorf("%s.base_url and %s.quote_asset must not be empty", exchange.name, exchange.name))
+		}
+		if exchange.cfg.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s.timeout must be positive", exchange.name))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// ParseConsistency checks a consistency level name such as QUORUM or ONE
+func ParseConsistency(name string) (string, error) {
+	level := strings.ToUpper(name)
+	switch level {
+	case "ANY", "ONE", "TWO", "THREE", "QUORUM", "ALL",
+		"LOCAL_QUORUM", "EACH_QUORUM", "LOCAL_ONE":
+		return level, nil
+	}
+	return "", fmt.Errorf("scylla.consistency '%s' is not a valid consistency level", name)
+}
+
+// Addr returns the listen address of the HTTP server
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+func envString(key string, target *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*target = value
+	}
+}
+
+func envList(key string, target *[]string) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*target = items
+}
+
+func envInt(key string, target *int, errs *[]error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be an integer, got '%s'", key, value))
+		return
+	}
+	*target = n
+}
+
+func envFloat(key string, target *float64, errs *[]error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be a number, got '%s'", key, value))
+		return
+	}
+	*target = f
+}
+
+func envDuration(key string, target *time.Duration, errs *[]error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be a duration such as 30s or 1m, got '%s'", key, value))
+		return
+	}
+	*target = d
+}
@@ -0,0 +1,218 @@
+// Package costbasis computes realized gains from a portfolio ledger by
+// matching disposals against acquisition lots with a selectable method.
+package costbasis
+
+import (
+	"crypto-portfolio-tracker/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Method selects which lots a disposal consumes first
+type Method string
+
+const (
+	FIFO        Method = "fifo"
+	LIFO        Method = "lifo"
+	HIFO        Method = "hifo"
+	AverageCost Method = "average"
+)
+
+// amountEpsilon absorbs floating point noise when consuming lots
+const amountEpsilon = 1e-9
+
+// ParseMethod converts a user supplied name into a Method
+func ParseMethod(name string) (Method, error) {
+	switch Method(strings.ToLower(name)) {
+	case FIFO:
+		return FIFO, nil
+	case LIFO:
+		return LIFO, nil
+	case HIFO:
+		return HIFO, nil
+	case AverageCost, "avg", "average_cost":
+		return AverageCost, nil
+	}
+	return "", fmt.Errorf("unknown cost basis method '%s' (use fifo, lifo, hifo or average)", name)
+}
+
+// Lot is a quantity of a token acquired at a single time and unit cost
+type Lot struct {
+	TokenID    string    `json:"token_id"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Amount     float64   `json:"amount"`
+	UnitCost   float64   `json:"unit_cost"`
+}
+
+// Disposal is the part of a sell matched against a single lot
+type Disposal struct {
+	TransactionID string    `json:"transaction_id"`
+	TokenID       string    `json:"token_id"`
+	Amount        float64   `json:"amount"`
+	AcquiredAt    time.Time `json:"acquired_at"`
+	DisposedAt    time.Time `json:"disposed_at"`
+	Proceeds      float64   `json:"proceeds"`
+	CostBasis     float64   `json:"cost_basis"`
+	Gain          float64   `json:"gain"`
+}
+
+// Result holds all realized disposals and the lots still open afterwards
+type Result struct {
+	Method         Method     `json:"method"`
+	Disposals      []Disposal `json:"disposals"`
+	OpenLots       []Lot      `json:"open_lots"`
+	TotalProceeds  float64    `json:"total_proceeds"`
+	TotalCostBasis float64    `json:"total_cost_basis"`
+	TotalGain      float64    `json:"total_gain"`
+}
+
+// Calculate replays a chronologically sorted ledger and realizes gains on every sell.
+// Buys and deposits open lots; withdrawals and fees consume lots without realizing
+// a gain; transfers between wallets leave lots untouched.
+func Calculate(transactions []models.Transaction, method Method) (*Result, error) {
+	if _, err := ParseMethod(string(method)); err != nil {
+		return nil, err
+	}
+
+	lots := make(map[string][]Lot)
+	result := &Result{Method: method, Disposals: make([]Disposal, 0), OpenLots: make([]Lot, 0)}
+	order := make([]string, 0)
+
+	for _, tx := range transactions {
+		if _, ok := lots[tx.TokenID]; !ok {
+			order = append(order, tx.TokenID)
+			lots[tx.TokenID] = make([]Lot, 0)
+		}
+
+		switch tx.Type {
+		case models.TransactionBuy, models.TransactionDeposit:
+			cost := tx.Amount * tx.Price
+			if tx.Type == models.TransactionBuy {
+				cost += tx.Fee
+			}
+			lots[tx.TokenID] = append(lots[tx.TokenID], Lot{
+				TokenID:    tx.TokenID,
+				AcquiredAt: tx.Timestamp,
+				Amount:     tx.Amount,
+				UnitCost:   cost / tx.Amount,
+			})
+			if method == AverageCost {
+				averageLots(lots[tx.TokenID])
+			}
+
+		case models.TransactionSell:
+			remaining, slices, err := consume(lots[tx.TokenID], tx, method)
+			if err != nil {
+				return nil, err
+			}
+			lots[tx.TokenID] = remaining
+
+			proceeds := tx.Amount*tx.Price - tx.Fee
+			for _, slice := range slices {
+				share := proceeds * (slice.Amount / tx.Amount)
+				disposal := Disposal{
+					TransactionID: tx.ID,
+					TokenID:       tx.TokenID,
+					Amount:        slice.Amount,
+					AcquiredAt:    slice.AcquiredAt,
+					DisposedAt:    tx.Timestamp,
+					Proceeds:      share,
+					CostBasis:     slice.Amount * slice.UnitCost,
+				}
+				disposal.Gain = disposal.Proceeds - disposal.CostBasis
+				result.Disposals = append(result.Disposals, disposal)
+
+				result.TotalProceeds += disposal.Proceeds
+				result.TotalCostBasis += disposal.CostBasis
+				result.TotalGain += disposal.Gain
+			}
+
+		case models.TransactionWithdraw, models.TransactionFee:
+			remaining, _, err := consume(lots[tx.TokenID], tx, method)
+			if err != nil {
+				return nil, err
+			}
+			lots[tx.TokenID] = remaining
+		}
+	}
+
+	for _, tokenID := range order {
+		result.OpenLots = append(result.OpenLots, lots[tokenID]...)
+	}
+
+	return result, nil
+}
+
+// consume removes tx.Amount from the lots in the order given by method and
+// returns the remaining lots (in acquisition order) and the consumed slices
+func consume(lots []Lot, tx models.Transaction, method Method) ([]Lot, []Lot, error) {
+	available := 0.0
+	for _, lot := range lots {
+		available += lot.Amount
+	}
+	if available+amountEpsilon < tx.Amount {
+		return nil, nil, fmt.Errorf("%s of %g %s at %s exceeds available lots of %g",
+			tx.Type, tx.Amount, tx.TokenID, tx.Timestamp.Format(time.RFC3339), available)
+	}
+
+	// Lots are kept in acquisition order; pick indexes by the method's priority
+	indexes := make([]int, len(lots))
+	for i := range lots {
+		indexes[i] = i
+	}
+	switch method {
+	case LIFO:
+		sort.SliceStable(indexes, func(a, b int) bool { return indexes[a] > indexes[b] })
+	case HIFO:
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return lots[indexes[a]].UnitCost > lots[indexes[b]].UnitCost
+		})
+	}
+
+	remaining := make([]Lot, len(lots))
+	copy(remaining, lots)
+	slices := make([]Lot, 0)
+	needed := tx.Amount
+
+	for _, i := range indexes {
+		if needed <= amountEpsilon {
+			break
+		}
+		take := remaining[i].Amount
+		if take > needed {
+			take = needed
+		}
+		slice := remaining[i]
+		slice.Amount = take
+		slices = append(slices, slice)
+
+		remaining[i].Amount -= take
+		needed -= take
+	}
+
+	open := make([]Lot, 0, len(remaining))
+	for _, lot := range remaining {
+		if lot.Amount > amountEpsilon {
+			open = append(open, lot)
+		}
+	}
+
+	return open, slices, nil
+}
+
+// averageLots sets every lot's unit cost to the pooled average cost
+func averageLots(lots []Lot) {
+	amount, cost := 0.0, 0.0
+	for _, lot := range lots {
+		amount += lot.Amount
+		cost += lot.Amount * lot.UnitCost
+	}
+	if amount == 0 {
+		return
+	}
+	for i := range lots {
+		lots[i].UnitCost = cost / amount
+	}
+}
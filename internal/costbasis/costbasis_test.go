@@ -0,0 +1,235 @@
+package costbasis
+
+import (
+	"crypto-portfolio-tracker/internal/models"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tx builds a bitcoin transaction on the given day of the ledger
+func tx(day int, txType models.TransactionType, amount, price, fee float64) models.Transaction {
+	return models.Transaction{
+		ID:        string(txType) + "-" + start.AddDate(0, 0, day).Format("0102"),
+		TokenID:   "bitcoin",
+		Type:      txType,
+		Amount:    amount,
+		Price:     price,
+		Fee:       fee,
+		Wallet:    "default",
+		Timestamp: start.AddDate(0, 0, day),
+	}
+}
+
+// disposal is the part of a Disposal the tests check
+type disposal struct {
+	amount, proceeds, costBasis float64
+}
+
+// lot is the part of a Lot the tests check
+type lot struct {
+	amount, unitCost float64
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       Method
+		transactions []models.Transaction
+		disposals    []disposal
+		openLots     []lot
+		totalGain    float64
+		err          string
+	}{
+		{
+			name:   "fifo consumes the oldest lot and part of the next",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 1, 200, 0),
+				tx(2, models.TransactionSell, 1.5, 300, 0),
+			},
+			disposals: []disposal{{1, 300, 100}, {0.5, 150, 100}},
+			openLots:  []lot{{0.5, 200}},
+			totalGain: 250,
+		},
+		{
+			name:   "lifo consumes the newest lot and part of the previous",
+			method: LIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 1, 200, 0),
+				tx(2, models.TransactionSell, 1.5, 300, 0),
+			},
+			disposals: []disposal{{1, 300, 200}, {0.5, 150, 50}},
+			openLots:  []lot{{0.5, 100}},
+			totalGain: 200,
+		},
+		{
+			name:   "hifo consumes the costliest lot and part of the next costliest",
+			method: HIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 1, 300, 0),
+				tx(2, models.TransactionBuy, 1, 200, 0),
+				tx(3, models.TransactionSell, 1.5, 250, 0),
+			},
+			disposals: []disposal{{1, 250, 300}, {0.5, 125, 100}},
+			openLots:  []lot{{1, 100}, {0.5, 200}},
+			totalGain: -25,
+		},
+		{
+			name:   "average cost re-pools the remaining lot with a later buy",
+			method: AverageCost,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 1, 200, 0),
+				tx(2, models.TransactionSell, 1, 300, 0),
+				tx(3, models.TransactionBuy, 1, 300, 0),
+			},
+			disposals: []disposal{{1, 300, 150}},
+			openLots:  []lot{{1, 225}, {1, 225}},
+			totalGain: 150,
+		},
+		{
+			name:   "buy fees add to the unit cost",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 2, 100, 10),
+				tx(1, models.TransactionSell, 1, 150, 0),
+			},
+			disposals: []disposal{{1, 150, 105}},
+			openLots:  []lot{{1, 105}},
+			totalGain: 45,
+		},
+		{
+			name:   "sell fees split the proceeds across lots",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 3, 200, 0),
+				tx(2, models.TransactionSell, 2, 300, 20),
+			},
+			disposals: []disposal{{1, 290, 100}, {1, 290, 200}},
+			openLots:  []lot{{2, 200}},
+			totalGain: 280,
+		},
+		{
+			name:   "withdrawals and fees consume lots without realizing a gain",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionBuy, 1, 200, 0),
+				tx(2, models.TransactionWithdraw, 0.5, 0, 0),
+				tx(3, models.TransactionFee, 0.75, 0, 0),
+				tx(4, models.TransactionSell, 0.5, 400, 0),
+			},
+			disposals: []disposal{{0.5, 200, 100}},
+			openLots:  []lot{{0.25, 200}},
+			totalGain: 100,
+		},
+		{
+			name:   "transfers leave lots untouched",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionTransfer, 1, 0, 0),
+			},
+			disposals: []disposal{},
+			openLots:  []lot{{1, 100}},
+		},
+		{
+			name:   "selling more than the open lots fails",
+			method: FIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionSell, 1.5, 200, 0),
+			},
+			err: "exceeds available lots",
+		},
+		{
+			name:   "withdrawing more than the open lots fails",
+			method: HIFO,
+			transactions: []models.Transaction{
+				tx(0, models.TransactionBuy, 1, 100, 0),
+				tx(1, models.TransactionSell, 0.5, 200, 0),
+				tx(2, models.TransactionWithdraw, 1, 0, 0),
+			},
+			err: "exceeds available lots",
+		},
+		{
+			name:   "unknown methods are rejected",
+			method: "random",
+			err:    "unknown cost basis method",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Calculate(tt.transactions, tt.method)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Disposals) != len(tt.disposals) {
+				t.Fatalf("expected %d disposals, got %+v", len(tt.disposals), result.Disposals)
+			}
+			for i, want := range tt.disposals {
+				got := result.Disposals[i]
+				if !approx(got.Amount, want.amount) || !approx(got.Proceeds, want.proceeds) ||
+					!approx(got.CostBasis, want.costBasis) || !approx(got.Gain, want.proceeds-want.costBasis) {
+					t.Errorf("disposal %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+
+			if len(result.OpenLots) != len(tt.openLots) {
+				t.Fatalf("expected %d open lots, got %+v", len(tt.openLots), result.OpenLots)
+			}
+			for i, want := range tt.openLots {
+				got := result.OpenLots[i]
+				if !approx(got.Amount, want.amount) || !approx(got.UnitCost, want.unitCost) {
+					t.Errorf("open lot %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+
+			if !approx(result.TotalGain, tt.totalGain) {
+				t.Errorf("expected total gain %g, got %g", tt.totalGain, result.TotalGain)
+			}
+		})
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		want Method
+		err  bool
+	}{
+		{"fifo", FIFO, false},
+		{"LIFO", LIFO, false},
+		{"hifo", HIFO, false},
+		{"avg", AverageCost, false},
+		{"average_cost", AverageCost, false},
+		{"random", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMethod(tt.name)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseMethod(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
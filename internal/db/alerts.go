@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// SaveAlert inserts or replaces an alert. New alerts get a timeuuid derived
+// from their creation time, so a user's alerts are listed in creation order.
+func (db *ScyllaDB) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	id := gocql.UUIDFromTime(alert.CreatedAt)
+	if alert.ID != "" {
+		parsed, err := gocql.ParseUUID(alert.ID)
+		if err != nil {
+			return ErrInvalidID
+		}
+		id = parsed
+	}
+
+	query := `INSERT INTO alerts (user_id, id, type, token_id, threshold, time_window, cooldown, state, triggered_at, cooldown_until, created_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		alert.UserID, id, string(alert.Type), alert.TokenID, alert.Threshold, alert.Window, alert.Cooldown,
+		string(alert.State), alert.TriggeredAt, alert.CooldownUntil, alert.CreatedAt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save alert: %w", err)
+	}
+
+	alert.ID = id.String()
+	return nil
+}
+
+// UpdateAlertState writes the state columns of an alert. The conditional
+// update keeps the worker from recreating an alert deleted during evaluation.
+func (db *ScyllaDB) UpdateAlertState(ctx context.Context, alert models.Alert) error {
+	id, err := gocql.ParseUUID(alert.ID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `UPDATE alerts SET state = ?, triggered_at = ?, cooldown_until = ? 
+              WHERE user_id = ? AND id = ? IF EXISTS`
+
+	if err := db.Session.Query(query,
+		string(alert.State), alert.TriggeredAt, alert.CooldownUntil, alert.UserID, id).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to update alert state: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlert returns a single alert of a user
+func (db *ScyllaDB) GetAlert(ctx context.Context, userID, id string) (*models.Alert, error) {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT user_id, id, type, token_id, threshold, time_window, cooldown, state, triggered_at, cooldown_until, created_at 
+              FROM alerts WHERE user_id = ? AND id = ?`
+
+	alerts, err := db.scanAlerts(db.Session.Query(query, userID, uuid).WithContext(ctx).Iter())
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &alerts[0], nil
+}
+
+// GetAlerts returns a user's alerts in creation order
+func (db *ScyllaDB) GetAlerts(ctx context.Context, userID string) ([]models.Alert, error) {
+	query := `SELECT user_id, id, type, token_id, threshold, time_window, cooldown, state, triggered_at, cooldown_until, created_at 
+              FROM alerts WHERE user_id = ?`
+
+	return db.scanAlerts(db.Session.Query(query, userID).WithContext(ctx).Iter())
+}
+
+// ListAlerts returns the alerts of every user. It scans the whole table,
+// which the worker does once per sync.
+func (db *ScyllaDB) ListAlerts(ctx context.Context) ([]models.Alert, error) {
+	query := `SELECT user_id, id, type, token_id, threshold, time_window, cooldown, state, triggered_at, cooldown_until, created_at 
+              FROM alerts`
+
+	return db.scanAlerts(db.Session.Query(query).WithContext(ctx).Iter())
+}
+
+func (db *ScyllaDB) scanAlerts(iter *gocql.Iter) ([]models.Alert, error) {
+	alerts := make([]models.Alert, 0)
+	var alert models.Alert
+	var id gocql.UUID
+	var alertType, state string
+	var triggeredAt, cooldownUntil time.Time
+
+	for iter.Scan(&alert.UserID, &id, &alertType, &alert.TokenID, &alert.Threshold, &alert.Window,
+		&alert.Cooldown, &state, &triggeredAt, &cooldownUntil, &alert.CreatedAt) {
+		alert.ID = id.String()
+		alert.Type = models.AlertType(alertType)
+		alert.State = models.AlertState(state)
+		alert.TriggeredAt = optionalTime(triggeredAt)
+		alert.CooldownUntil = optionalTime(cooldownUntil)
+		alerts = append(alerts, alert)
+		alert = models.Alert{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// optionalTime maps the zero time scanned from a null column to nil
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// DeleteAlert removes an alert; its fired events are kept
+func (db *ScyllaDB) DeleteAlert(ctx context.Context, userID, id string) error {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `DELETE FROM alerts WHERE user_id = ? AND id = ?`
+
+	if err := db.Session.Query(query, userID, uuid).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to delete alert: %w", err)
+	}
+
+	return nil
+}
+
+// SaveAlertEvent appends a fired alert to its user's history
+func (db *ScyllaDB) SaveAlertEvent(ctx context.Context, event models.AlertEvent) error {
+	alertID, err := gocql.ParseUUID(event.AlertID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `INSERT INTO alert_events (user_id, triggered_at, alert_id, type, token_id, threshold, observed_value, message) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		event.UserID, event.TriggeredAt, alertID, string(event.Type), event.TokenID,
+		event.Threshold, event.Value, event.Message).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save alert event: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlertEvents returns up to limit of a user's fired alerts, newest first
+func (db *ScyllaDB) GetAlertEvents(ctx context.Context, userID string, limit int) ([]models.AlertEvent, error) {
+	query := `SELECT user_id, triggered_at, alert_id, type, token_id, threshold, observed_value, message 
+              FROM alert_events WHERE user_id = ? LIMIT ?`
+
+	iter := db.Session.Query(query, userID, limit).WithContext(ctx).Iter()
+
+	events := make([]models.AlertEvent, 0)
+	var event models.AlertEvent
+	var alertID gocql.UUID
+	var alertType string
+
+	for iter.Scan(&event.UserID, &event.TriggeredAt, &alertID, &alertType, &event.TokenID,
+		&event.Threshold, &event.Value, &event.Message) {
+		event.AlertID = alertID.String()
+		event.Type = models.AlertType(alertType)
+		events = append(events, event)
+		event = models.AlertEvent{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch alert events: %w", err)
+	}
+
+	return events, nil
+}
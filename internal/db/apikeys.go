@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// SaveAPIKey inserts an API key with a timeuuid derived from its creation
+// time, along with the lookup row of its hash
+func (db *ScyllaDB) SaveAPIKey(ctx context.Context, key *models.APIKey) error {
+	id := gocql.UUIDFromTime(key.CreatedAt)
+
+	query := `INSERT INTO api_keys (user_id, id, name, prefix, key_hash, scopes, created_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		key.UserID, id, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.CreatedAt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	query = `INSERT INTO api_keys_by_hash (key_hash, user_id, id) VALUES (?, ?, ?)`
+	if err := db.Session.Query(query, key.KeyHash, key.UserID, id).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to index API key: %w", err)
+	}
+
+	key.ID = id.String()
+	return nil
+}
+
+// GetAPIKey returns a single API key of a user
+func (db *ScyllaDB) GetAPIKey(ctx context.Context, userID, id string) (*models.APIKey, error) {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT user_id, id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at FROM api_keys 
+              WHERE user_id = ? AND id = ?`
+
+	keys, err := db.scanAPIKeys(db.Session.Query(query, userID, uuid).WithContext(ctx).Iter())
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &keys[0], nil
+}
+
+// GetAPIKeys returns a user's API keys, revoked ones included, in creation order
+func (db *ScyllaDB) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	query := `SELECT user_id, id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at FROM api_keys 
+              WHERE user_id = ?`
+
+	return db.scanAPIKeys(db.Session.Query(query, userID).WithContext(ctx).Iter())
+}
+
+// FindAPIKey returns the API key with a hash
+func (db *ScyllaDB) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT user_id, id FROM api_keys_by_hash WHERE key_hash = ?`
+
+	var userID string
+	var id gocql.UUID
+	if err := db.Session.Query(query, keyHash).WithContext(ctx).Scan(&userID, &id); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+
+	return db.GetAPIKey(ctx, userID, id.String())
+}
+
+// TouchAPIKey records when an API key was last used
+func (db *ScyllaDB) TouchAPIKey(ctx context.Context, userID, id string, t time.Time) error {
+	return db.setAPIKeyTime(ctx, "last_used_at", userID, id, t)
+}
+
+// RevokeAPIKey marks an API key as revoked; revoked keys stay listed
+func (db *ScyllaDB) RevokeAPIKey(ctx context.Context, userID, id string, t time.Time) error {
+	return db.setAPIKeyTime(ctx, "revoked_at", userID, id, t)
+}
+
+func (db *ScyllaDB) setAPIKeyTime(ctx context.Context, column, userID, id string, t time.Time) error {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `UPDATE api_keys SET ` + column + ` = ? WHERE user_id = ? AND id = ?`
+
+	if err := db.Session.Query(query, t, userID, uuid).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+
+	return nil
+}
+
+func (db *ScyllaDB) scanAPIKeys(iter *gocql.Iter) ([]models.APIKey, error) {
+	keys := make([]models.APIKey, 0)
+	var key models.APIKey
+	var id gocql.UUID
+	var lastUsedAt, revokedAt time.Time
+
+	for iter.Scan(&key.UserID, &id, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes,
+		&key.CreatedAt, &lastUsedAt, &revokedAt) {
+		key.ID = id.String()
+		key.LastUsedAt = optionalTime(lastUsedAt)
+		key.RevokedAt = optionalTime(revokedAt)
+		keys = append(keys, key)
+		key = models.APIKey{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch API keys: %w", err)
+	}
+
+	return keys, nil
+}
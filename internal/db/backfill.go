@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// SaveBackfill inserts or replaces the backfill progress of a token
+func (db *ScyllaDB) SaveBackfill(ctx context.Context, state models.BackfillState) error {
+	query := `INSERT INTO backfill_state (token_id, from_time, to_time, resume_at, points, status, error, updated_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		state.TokenID, state.From, state.To, state.ResumeAt, state.Points,
+		string(state.Status), state.Error, state.UpdatedAt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save backfill state: %w", err)
+	}
+
+	return nil
+}
+
+// GetBackfill returns the backfill progress of a token
+func (db *ScyllaDB) GetBackfill(ctx context.Context, tokenID string) (*models.BackfillState, error) {
+	query := `SELECT token_id, from_time, to_time, resume_at, points, status, error, updated_at 
+              FROM backfill_state WHERE token_id = ?`
+
+	var state models.BackfillState
+	var status string
+	if err := db.Session.Query(query, tokenID).WithContext(ctx).Scan(
+		&state.TokenID, &state.From, &state.To, &state.ResumeAt, &state.Points,
+		&status, &state.Error, &state.UpdatedAt); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get backfill state: %w", err)
+	}
+	state.Status = models.BackfillStatus(status)
+
+	return &state, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// SaveCandles writes the candles of one series in unlogged batches. Candles
+// expire after the retention of their interval, which also bounds the
+// partitions of the fine intervals.
+func (db *ScyllaDB) SaveCandles(ctx context.Context, tokenID, interval string, candles []models.Candle) error {
+	ttl := ttlSeconds(db.Retention.CandleTTL(interval))
+	return db.saveCandles(ctx, tokenID, interval, candles, func(models.Candle) int { return ttl })
+}
+
+// saveCandles writes the candles of one series, each with the TTL returned for it
+func (db *ScyllaDB) saveCandles(ctx context.Context, tokenID, interval string, candles []models.Candle, ttl func(models.Candle) int) error {
+	query := `INSERT INTO price_candles (token_id, resolution, bucket_start, open, high, low, close, count, open_time, close_time) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?`
+
+	for start := 0; start < len(candles); start += priceBatchSize {
+		batch := db.Session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for _, c := range candles[start:min(start+priceBatchSize, len(candles))] {
+			batch.Query(query, tokenID, interval, c.Start,
+				c.Open, c.High, c.Low, c.Close, c.Count, c.OpenTime, c.CloseTime, ttl(c))
+		}
+
+		if err := db.Session.ExecuteBatch(batch); err != nil {
+			return fmt.Errorf("failed to save candles: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetCandle returns the candle of a series starting at start
+func (db *ScyllaDB) GetCandle(ctx context.Context, tokenID, interval string, start time.Time) (*models.Candle, error) {
+	query := `SELECT bucket_start, open, high, low, close, count, open_time, close_time FROM price_candles 
+              WHERE token_id = ? AND resolution = ? AND bucket_start = ?`
+
+	var c models.Candle
+	if err := db.Session.Query(query, tokenID, interval, start).WithContext(ctx).Scan(
+		&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Count, &c.OpenTime, &c.CloseTime); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get candle: %w", err)
+	}
+
+	return &c, nil
+}
+
+// GetCandles returns the candles of a series starting between from and to, oldest first
+func (db *ScyllaDB) GetCandles(ctx context.Context, tokenID, interval string, from, to time.Time) ([]models.Candle, error) {
+	query := `SELECT bucket_start, open, high, low, close, count, open_time, close_time FROM price_candles 
+              WHERE token_id = ? AND resolution = ? AND bucket_start >= ? AND bucket_start <= ?`
+
+	iter := db.Session.Query(query, tokenID, interval, from, to).WithContext(ctx).Iter()
+
+	candles := make([]models.Candle, 0)
+	var c models.Candle
+	for iter.Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Count, &c.OpenTime, &c.CloseTime) {
+		candles = append(candles, c)
+		c = models.Candle{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch candles: %w", err)
+	}
+
+	return candles, nil
+}
+
+// rollupIntervals are the candle series, finest first, that stand in for raw
+// ticks once those have expired
+var rollupIntervals = []string{"1h", "1d"}
+
+var rollupDurations = map[string]time.Duration{
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// latestCandles returns the two newest candles of a series starting at or before t, newest first
+func (db *ScyllaDB) latestCandles(ctx context.Context, tokenID, interval string, t time.Time) ([]models.Candle, error) {
+	query := `SELECT bucket_start, open, high, low, close, count, open_time, close_time FROM price_candles 
+              WHERE token_id = ? AND resolution = ? AND bucket_start <= ? ORDER BY bucket_start DESC LIMIT 2`
+
+	iter := db.Session.Query(query, tokenID, interval, t).WithContext(ctx).Iter()
+
+	candles := make([]models.Candle, 0, 2)
+	var c models.Candle
+	for iter.Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Count, &c.OpenTime, &c.CloseTime) {
+		candles = append(candles, c)
+		c = models.Candle{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch candles: %w", err)
+	}
+
+	return candles, nil
+}
+
+// candlePointAt returns the latest tick at or before t recorded in candles,
+// newest first: a candle's close, or its open while t is inside it
+func candlePointAt(tokenID string, candles []models.Candle, t time.Time) (*models.PriceHistory, bool) {
+	for _, c := range candles {
+		switch {
+		case !c.CloseTime.After(t):
+			return &models.PriceHistory{TokenID: tokenID, Price: c.Close, Timestamp: c.CloseTime}, true
+		case !c.OpenTime.After(t):
+			return &models.PriceHistory{TokenID: tokenID, Price: c.Open, Timestamp: c.OpenTime}, true
+		}
+	}
+	return nil, false
+}
+
+// rollupRange returns the closes of the candles between from and end, oldest
+// first. 1h candles are used where they exist and 1d candles before them.
+func rollupRange(ctx context.Context, store CandleStore, tokenID string, from, end time.Time) ([]models.PriceHistory, error) {
+	points := make([]models.PriceHistory, 0)
+	for _, interval := range rollupIntervals {
+		candles, err := store.GetCandles(ctx, tokenID, interval, from, end)
+		if err != nil {
+			return nil, err
+		}
+
+		closes := make([]models.PriceHistory, 0, len(candles))
+		for _, c := range candles {
+			if c.CloseTime.Before(end) {
+				closes = append(closes, models.PriceHistory{TokenID: tokenID, Price: c.Close, Timestamp: c.CloseTime})
+			}
+		}
+		if len(closes) > 0 {
+			points = append(closes, points...)
+			end = closes[0].Timestamp
+		}
+	}
+	return points, nil
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"bytes"
 	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,14 +14,15 @@ import (
 
 type ElasticSearch struct {
 	Client *elasticsearch.Client
+	Index  string
 }
 
-func NewElasticSearch(addresses []string) (*ElasticSearch, error) {
-	cfg := elasticsearch.Config{
-		Addresses: addresses,
+func NewElasticSearch(cfg config.ElasticConfig) (*ElasticSearch, error) {
+	esCfg := elasticsearch.Config{
+		Addresses: cfg.Addresses,
 	}
 
-	client, err := elasticsearch.NewClient(cfg)
+	client, err := elasticsearch.NewClient(esCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
 	}
@@ -32,67 +35,24 @@ func NewElasticSearch(addresses []string) (*ElasticSearch, error) {
 	defer res.Body.Close()
 
 	log.Println("✅ Connected to ElasticSearch")
-	return &ElasticSearch{Client: client}, nil
+	return &ElasticSearch{Client: client, Index: cfg.Index}, nil
 }
 
+// InitIndex migrates the token index to the latest mapping version
 func (es *ElasticSearch) InitIndex() error {
-	indexName := "crypto_tokens"
-
-	// Check if index exists
-	res, err := es.Client.Indices.Exists([]string{indexName})
-	if err != nil {
-		return fmt.Errorf("failed to check index: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		log.Printf("✅ Index '%s' already exists", indexName)
-		return nil
-	}
-
-	// Create index with mapping
-	mapping := map[string]interface{}{
-		"mappings": map[string]interface{}{
-			"properties": map[string]interface{}{
-				"id":            map[string]interface{}{"type": "keyword"},
-				"symbol":        map[string]interface{}{"type": "keyword"},
-				"name":          map[string]interface{}{"type": "text"},
-				"current_price": map[string]interface{}{"type": "double"},
-				"market_cap":    map[string]interface{}{"type": "double"},
-				"volume_24h":    map[string]interface{}{"type": "double"},
-				"updated_at":    map[string]interface{}{"type": "date"},
-			},
-		},
-	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(mapping); err != nil {
-		return fmt.Errorf("failed to encode mapping: %w", err)
-	}
-
-	res, err = es.Client.Indices.Create(
-		indexName,
-		es.Client.Indices.Create.WithBody(&buf),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create index: %w", err)
-	}
-	defer res.Body.Close()
-
-	log.Printf("✅ Created index '%s'", indexName)
-	return nil
+	return es.MigrateIndex(context.Background(), LatestIndexVersion())
 }
 
-func (es *ElasticSearch) IndexToken(ctx context.Context, token map[string]interface{}) error {
+func (es *ElasticSearch) IndexToken(ctx context.Context, token models.Token) error {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(token); err != nil {
 		return fmt.Errorf("failed to encode token: %w", err)
 	}
 
 	res, err := es.Client.Index(
-		"crypto_tokens",
+		es.Index,
 		&buf,
-		es.Client.Index.WithDocumentID(token["id"].(string)),
+		es.Client.Index.WithDocumentID(token.ID),
 		es.Client.Index.WithContext(ctx),
 		es.Client.Index.WithRefresh("true"),
 	)
@@ -101,11 +61,14 @@ func (es *ElasticSearch) IndexToken(ctx context.Context, token map[string]interf
 	}
 	defer res.Body.Close()
 
+	if res.IsError() {
+		return fmt.Errorf("failed to index token: %s", res.Status())
+	}
+
 	return nil
 }
 
-func (es *ElasticSearch) SearchTokens(ctx context.Context, query string) ([]map[string]interface{}, error) {
-	var buf bytes.Buffer
+func (es *ElasticSearch) SearchTokens(ctx context.Context, query string) ([]models.Token, error) {
 	searchQuery := map[string]interface{}{
 		"query": map[string]interface{}{
 			"multi_match": map[string]interface{}{
@@ -115,32 +78,93 @@ func (es *ElasticSearch) SearchTokens(ctx context.Context, query string) ([]map[
 		},
 	}
 
-	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
-		return nil, fmt.Errorf("failed to encode search query: %w", err)
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source models.Token `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := es.search(ctx, searchQuery, &result); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]models.Token, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		tokens = append(tokens, hit.Source)
+	}
+
+	return tokens, nil
+}
+
+func (es *ElasticSearch) Analytics(ctx context.Context) (map[string]interface{}, error) {
+	// Aggregation query
+	aggQuery := map[string]interface{}{
+		"size": 0,
+		"aggs": map[string]interface{}{
+			"avg_price": map[string]interface{}{
+				"avg": map[string]interface{}{
+					"field": "current_price",
+				},
+			},
+			"total_market_cap": map[string]interface{}{
+				"sum": map[string]interface{}{
+					"field": "market_cap",
+				},
+			},
+			"top_tokens": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "symbol",
+					"size":  10,
+					"order": map[string]interface{}{
+						"by_market_cap": "desc",
+					},
+				},
+				"aggs": map[string]interface{}{
+					"by_market_cap": map[string]interface{}{
+						"max": map[string]interface{}{
+							"field": "market_cap",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var result struct {
+		Aggregations map[string]interface{} `json:"aggregations"`
+	}
+	if err := es.search(ctx, aggQuery, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Aggregations, nil
+}
+
+// search runs a query against the token index and decodes the response into out
+func (es *ElasticSearch) search(ctx context.Context, query map[string]interface{}, out interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return fmt.Errorf("failed to encode search query: %w", err)
 	}
 
 	res, err := es.Client.Search(
 		es.Client.Search.WithContext(ctx),
-		es.Client.Search.WithIndex("crypto_tokens"),
+		es.Client.Search.WithIndex(es.Index),
 		es.Client.Search.WithBody(&buf),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search: %w", err)
+		return fmt.Errorf("failed to search: %w", err)
 	}
 	defer res.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if res.IsError() {
+		return fmt.Errorf("failed to search: %s", res.Status())
 	}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	tokens := make([]map[string]interface{}, 0, len(hits))
-
-	for _, hit := range hits {
-		source := hit.(map[string]interface{})["_source"].(map[string]interface{})
-		tokens = append(tokens, source)
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return tokens, nil
+	return nil
 }
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SaveRates inserts or replaces the exchange rate of each currency
+func (db *ScyllaDB) SaveRates(ctx context.Context, rates map[string]float64, updatedAt time.Time) error {
+	query := `INSERT INTO fx_rates (currency, rate, updated_at) VALUES (?, ?, ?)`
+
+	for currency, rate := range rates {
+		if err := db.Session.Query(query, currency, rate, updatedAt).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to save exchange rate for %s: %w", currency, err)
+		}
+	}
+
+	return nil
+}
+
+// GetRates returns every stored exchange rate keyed by currency
+func (db *ScyllaDB) GetRates(ctx context.Context) (map[string]float64, error) {
+	query := `SELECT currency, rate FROM fx_rates`
+	iter := db.Session.Query(query).WithContext(ctx).Iter()
+
+	rates := make(map[string]float64)
+	var currency string
+	var rate float64
+	for iter.Scan(&currency, &rate) {
+		rates[currency] = rate
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,255 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// tokenMappings are the versions of the token index mapping, oldest first
+// and only ever appended to. Version N lives in the index <index>_v<N> and
+// the configured index name is an alias of the current version, so a new
+// mapping is rolled out by reindexing into a new index and moving the alias.
+var tokenMappings = []map[string]interface{}{
+	// 1: initial mapping
+	{
+		"properties": map[string]interface{}{
+			"id":            map[string]interface{}{"type": "keyword"},
+			"symbol":        map[string]interface{}{"type": "keyword"},
+			"name":          map[string]interface{}{"type": "text"},
+			"current_price": map[string]interface{}{"type": "double"},
+			"market_cap":    map[string]interface{}{"type": "double"},
+			"volume_24h":    map[string]interface{}{"type": "double"},
+			"updated_at":    map[string]interface{}{"type": "date"},
+		},
+	},
+	// 2: price sources and per-currency quotes
+	{
+		// Map every quote currency as a double, even when the first value is whole
+		"dynamic_templates": []interface{}{
+			map[string]interface{}{
+				"quotes": map[string]interface{}{
+					"path_match": "quotes.*",
+					"mapping":    map[string]interface{}{"type": "double"},
+				},
+			},
+		},
+		"properties": map[string]interface{}{
+			"id":            map[string]interface{}{"type": "keyword"},
+			"symbol":        map[string]interface{}{"type": "keyword"},
+			"name":          map[string]interface{}{"type": "text"},
+			"current_price": map[string]interface{}{"type": "double"},
+			"market_cap":    map[string]interface{}{"type": "double"},
+			"volume_24h":    map[string]interface{}{"type": "double"},
+			"updated_at":    map[string]interface{}{"type": "date"},
+			"sources":       map[string]interface{}{"type": "keyword"},
+			"quotes":        map[string]interface{}{"type": "object"},
+		},
+	},
+}
+
+// LatestIndexVersion is the version of the newest token index mapping
+func LatestIndexVersion() int {
+	return len(tokenMappings)
+}
+
+func (es *ElasticSearch) versionedIndex(version int) string {
+	return fmt.Sprintf("%s_v%d", es.Index, version)
+}
+
+// IndexVersion returns the mapping version the index alias points at, or 0
+// when there is no versioned index yet
+func (es *ElasticSearch) IndexVersion(ctx context.Context) (int, error) {
+	res, err := es.Client.Indices.GetAlias(
+		es.Client.Indices.GetAlias.WithName(es.Index),
+		es.Client.Indices.GetAlias.WithContext(ctx),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get index alias: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return 0, nil
+	}
+	if res.IsError() {
+		return 0, fmt.Errorf("failed to get index alias: %s", res.Status())
+	}
+
+	var indices map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return 0, fmt.Errorf("failed to decode index alias: %w", err)
+	}
+
+	for name := range indices {
+		if suffix, ok := strings.CutPrefix(name, es.Index+"_v"); ok {
+			if version, err := strconv.Atoi(suffix); err == nil {
+				return version, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("alias '%s' does not point at a versioned index", es.Index)
+}
+
+// MigrateIndex moves the index alias to the given mapping version, upgrading
+// or downgrading. The target index is created with its mapping, documents are
+// copied with a reindex, the alias is swapped atomically and the old index is
+// deleted. An unversioned index created by earlier versions is migrated too.
+func (es *ElasticSearch) MigrateIndex(ctx context.Context, target int) error {
+	if target < 1 || target > LatestIndexVersion() {
+		return fmt.Errorf("unknown index version %d (latest is %d)", target, LatestIndexVersion())
+	}
+
+	current, err := es.IndexVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current == target {
+		log.Printf("✅ Index '%s' is at version %d", es.Index, current)
+		return nil
+	}
+
+	// Before versioning the alias name was a plain index
+	source := ""
+	if current > 0 {
+		source = es.versionedIndex(current)
+	} else {
+		exists, err := es.indexExists(ctx, es.Index)
+		if err != nil {
+			return err
+		}
+		if exists {
+			source = es.Index
+		}
+	}
+
+	dest := es.versionedIndex(target)
+
+	// Drop leftovers of an interrupted migration to the same version
+	if err := es.deleteIndex(ctx, dest); err != nil {
+		return err
+	}
+	if err := es.createIndex(ctx, dest, tokenMappings[target-1]); err != nil {
+		return err
+	}
+
+	if source != "" {
+		if err := es.reindex(ctx, source, dest); err != nil {
+			return err
+		}
+	}
+
+	actions := []interface{}{
+		map[string]interface{}{"add": map[string]interface{}{"index": dest, "alias": es.Index}},
+	}
+	switch {
+	case current > 0:
+		actions = append([]interface{}{
+			map[string]interface{}{"remove": map[string]interface{}{"index": source, "alias": es.Index}},
+		}, actions...)
+	case source != "":
+		// The alias cannot be added while an index of that name exists
+		if err := es.deleteIndex(ctx, source); err != nil {
+			return err
+		}
+	}
+	if err := es.updateAliases(ctx, actions); err != nil {
+		return err
+	}
+
+	if current > 0 {
+		if err := es.deleteIndex(ctx, source); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Migrated index '%s' from version %d to %d", es.Index, current, target)
+	return nil
+}
+
+func (es *ElasticSearch) indexExists(ctx context.Context, name string) (bool, error) {
+	res, err := es.Client.Indices.Exists([]string{name}, es.Client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to check index: %w", err)
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode == 200, nil
+}
+
+func (es *ElasticSearch) createIndex(ctx context.Context, name string, mapping map[string]interface{}) error {
+	body, err := encodeBody(map[string]interface{}{"mappings": mapping})
+	if err != nil {
+		return err
+	}
+
+	res, err := es.Client.Indices.Create(name,
+		es.Client.Indices.Create.WithBody(body),
+		es.Client.Indices.Create.WithContext(ctx),
+	)
+	return checkResponse(res, err, "create index "+name)
+}
+
+// deleteIndex deletes an index, ignoring one that does not exist
+func (es *ElasticSearch) deleteIndex(ctx context.Context, name string) error {
+	res, err := es.Client.Indices.Delete([]string{name},
+		es.Client.Indices.Delete.WithIgnoreUnavailable(true),
+		es.Client.Indices.Delete.WithContext(ctx),
+	)
+	return checkResponse(res, err, "delete index "+name)
+}
+
+func (es *ElasticSearch) reindex(ctx context.Context, source, dest string) error {
+	body, err := encodeBody(map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := es.Client.Reindex(body,
+		es.Client.Reindex.WithWaitForCompletion(true),
+		es.Client.Reindex.WithRefresh(true),
+		es.Client.Reindex.WithContext(ctx),
+	)
+	return checkResponse(res, err, fmt.Sprintf("reindex %s into %s", source, dest))
+}
+
+func (es *ElasticSearch) updateAliases(ctx context.Context, actions []interface{}) error {
+	body, err := encodeBody(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	res, err := es.Client.Indices.UpdateAliases(body, es.Client.Indices.UpdateAliases.WithContext(ctx))
+	return checkResponse(res, err, "update aliases")
+}
+
+func encodeBody(body map[string]interface{}) (io.Reader, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	return &buf, nil
+}
+
+// checkResponse closes the response and turns transport and API failures into errors
+func checkResponse(res *esapi.Response, err error, action string) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("failed to %s: %s", action, res.String())
+	}
+	return nil
+}
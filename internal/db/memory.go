@@ -0,0 +1,824 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// MemoryStore implements every store in process memory.
+// It is meant for tests and local development without ScyllaDB or ElasticSearch.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	tokens       map[string]models.Token
+	prices       map[string][]models.PriceHistory // oldest first
+	candles      map[string][]models.Candle       // by token and interval, oldest first
+	holdings     map[string]map[string]models.Portfolio
+	transactions map[string][]models.Transaction
+	rates        map[string]float64
+	backfills    map[string]models.BackfillState
+	alerts       map[string]map[string]models.Alert
+	alertEvents  map[string][]models.AlertEvent // oldest first
+	webhooks     map[string]map[string]models.Webhook
+	deliveries   map[string]map[string]models.WebhookDelivery
+	users        map[string]models.User
+	apiKeys      map[string]map[string]models.APIKey
+	apiKeyHashes map[string]models.APIKey
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:       make(map[string]models.Token),
+		prices:       make(map[string][]models.PriceHistory),
+		candles:      make(map[string][]models.Candle),
+		holdings:     make(map[string]map[string]models.Portfolio),
+		transactions: make(map[string][]models.Transaction),
+		rates:        make(map[string]float64),
+		backfills:    make(map[string]models.BackfillState),
+		alerts:       make(map[string]map[string]models.Alert),
+		alertEvents:  make(map[string][]models.AlertEvent),
+		webhooks:     make(map[string]map[string]models.Webhook),
+		deliveries:   make(map[string]map[string]models.WebhookDelivery),
+		users:        make(map[string]models.User),
+		apiKeys:      make(map[string]map[string]models.APIKey),
+		apiKeyHashes: make(map[string]models.APIKey),
+	}
+}
+
+var (
+	_ TokenStore        = (*MemoryStore)(nil)
+	_ PriceHistoryStore = (*MemoryStore)(nil)
+	_ CandleStore       = (*MemoryStore)(nil)
+	_ SearchIndex       = (*MemoryStore)(nil)
+	_ PortfolioStore    = (*MemoryStore)(nil)
+	_ TransactionStore  = (*MemoryStore)(nil)
+	_ FXStore           = (*MemoryStore)(nil)
+	_ BackfillStore     = (*MemoryStore)(nil)
+	_ AlertStore        = (*MemoryStore)(nil)
+	_ WebhookStore      = (*MemoryStore)(nil)
+	_ UserStore         = (*MemoryStore)(nil)
+	_ APIKeyStore       = (*MemoryStore)(nil)
+)
+
+func (m *MemoryStore) SaveToken(ctx context.Context, token models.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[token.ID] = token
+	return nil
+}
+
+func (m *MemoryStore) GetToken(ctx context.Context, id string) (*models.Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	token, ok := m.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (m *MemoryStore) ListTokens(ctx context.Context) ([]models.Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tokens := make([]models.Token, 0, len(m.tokens))
+	for _, token := range m.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+func (m *MemoryStore) GetTokenPrices(ctx context.Context, tokenIDs []string) (map[string]float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prices := make(map[string]float64, len(tokenIDs))
+	for _, id := range tokenIDs {
+		if token, ok := m.tokens[id]; ok {
+			prices[id] = token.CurrentPrice
+		}
+	}
+	return prices, nil
+}
+
+// SavePrice keeps each token's points sorted; a point at an existing
+// timestamp replaces it, like an upsert on the ScyllaDB primary key
+func (m *MemoryStore) SavePrice(ctx context.Context, point models.PriceHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.savePrice(point)
+	return nil
+}
+
+func (m *MemoryStore) SavePrices(ctx context.Context, points []models.PriceHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, point := range points {
+		m.savePrice(point)
+	}
+	return nil
+}
+
+func (m *MemoryStore) savePrice(point models.PriceHistory) {
+	points := m.prices[point.TokenID]
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(point.Timestamp)
+	})
+	if i < len(points) && points[i].Timestamp.Equal(point.Timestamp) {
+		points[i] = point
+		return
+	}
+
+	points = append(points, models.PriceHistory{})
+	copy(points[i+1:], points[i:])
+	points[i] = point
+	m.prices[point.TokenID] = points
+}
+
+// GetPriceHistory pages newest first; the cursor encodes the timestamp of the
+// last point returned, so the next page starts just before it
+func (m *MemoryStore) GetPriceHistory(ctx context.Context, tokenID string, q PriceQuery) ([]models.PriceHistory, string, error) {
+	to := q.To
+	if q.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		nanos, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		to = time.Unix(0, nanos-1)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	points := m.prices[tokenID]
+	history := make([]models.PriceHistory, 0)
+	i := len(points) - 1
+	for ; i >= 0 && len(history) < q.Limit; i-- {
+		point := points[i]
+		if !to.IsZero() && point.Timestamp.After(to) {
+			continue
+		}
+		if !q.From.IsZero() && point.Timestamp.Before(q.From) {
+			break
+		}
+		history = append(history, point)
+	}
+
+	// Like the ScyllaDB paging state, a full page always yields a cursor
+	next := ""
+	if len(history) == q.Limit && len(history) > 0 {
+		last := history[len(history)-1].Timestamp.UnixNano()
+		next = base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(last, 10)))
+	}
+	return history, next, nil
+}
+
+func (m *MemoryStore) GetPointAt(ctx context.Context, tokenID string, t time.Time) (*models.PriceHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	points := m.prices[tokenID]
+	i := sort.Search(len(points), func(i int) bool {
+		return points[i].Timestamp.After(t)
+	})
+	if i > 0 {
+		point := points[i-1]
+		return &point, nil
+	}
+
+	for _, interval := range rollupIntervals {
+		series := m.candles[tokenID+"/"+interval]
+		j := sort.Search(len(series), func(j int) bool {
+			return series[j].Start.After(t)
+		})
+		latest := make([]models.Candle, 0, 2)
+		for k := j - 1; k >= 0 && k >= j-2; k-- {
+			latest = append(latest, series[k])
+		}
+		if point, ok := candlePointAt(tokenID, latest, t); ok {
+			return point, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) GetPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error) {
+	points, err := m.GetRawPriceRange(ctx, tokenID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	end := to
+	if len(points) > 0 {
+		end = points[0].Timestamp
+	}
+	rollups, err := rollupRange(ctx, m, tokenID, from, end)
+	if err != nil {
+		return nil, err
+	}
+	return append(rollups, points...), nil
+}
+
+func (m *MemoryStore) GetRawPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	points := make([]models.PriceHistory, 0)
+	for _, point := range m.prices[tokenID] {
+		if !point.Timestamp.Before(from) && !point.Timestamp.After(to) {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+// SaveCandles keeps each series sorted by start, replacing candles with an equal start
+func (m *MemoryStore) SaveCandles(ctx context.Context, tokenID, interval string, candles []models.Candle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := tokenID + "/" + interval
+	series := m.candles[key]
+	for _, candle := range candles {
+		i := sort.Search(len(series), func(i int) bool {
+			return !series[i].Start.Before(candle.Start)
+		})
+		if i < len(series) && series[i].Start.Equal(candle.Start) {
+			series[i] = candle
+			continue
+		}
+
+		series = append(series, models.Candle{})
+		copy(series[i+1:], series[i:])
+		series[i] = candle
+	}
+	m.candles[key] = series
+	return nil
+}
+
+func (m *MemoryStore) GetCandle(ctx context.Context, tokenID, interval string, start time.Time) (*models.Candle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, candle := range m.candles[tokenID+"/"+interval] {
+		if candle.Start.Equal(start) {
+			return &candle, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) GetCandles(ctx context.Context, tokenID, interval string, from, to time.Time) ([]models.Candle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candles := make([]models.Candle, 0)
+	for _, candle := range m.candles[tokenID+"/"+interval] {
+		if !candle.Start.Before(from) && !candle.Start.After(to) {
+			candles = append(candles, candle)
+		}
+	}
+	return candles, nil
+}
+
+// IndexToken is a no-op beyond SaveToken since search reads the token map directly
+func (m *MemoryStore) IndexToken(ctx context.Context, token models.Token) error {
+	return m.SaveToken(ctx, token)
+}
+
+// SearchTokens matches any query word against token names and symbols, case-insensitively
+func (m *MemoryStore) SearchTokens(ctx context.Context, query string) ([]models.Token, error) {
+	tokens, _ := m.ListTokens(ctx)
+	words := strings.Fields(strings.ToLower(query))
+
+	results := make([]models.Token, 0)
+	for _, token := range tokens {
+		name := strings.Fields(strings.ToLower(token.Name))
+		for _, word := range words {
+			if word == strings.ToLower(token.Symbol) || containsWord(name, word) {
+				results = append(results, token)
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+func containsWord(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) Analytics(ctx context.Context) (map[string]interface{}, error) {
+	tokens, _ := m.ListTokens(ctx)
+
+	var avgPrice interface{}
+	totalMarketCap := 0.0
+	if len(tokens) > 0 {
+		sum := 0.0
+		for _, token := range tokens {
+			sum += token.CurrentPrice
+			totalMarketCap += token.MarketCap
+		}
+		avgPrice = sum / float64(len(tokens))
+	}
+
+	// Group by symbol keeping the highest market cap, like the terms aggregation
+	bySymbol := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, token := range tokens {
+		if mc, ok := bySymbol[token.Symbol]; !ok || token.MarketCap > mc {
+			bySymbol[token.Symbol] = token.MarketCap
+		}
+		counts[token.Symbol]++
+	}
+
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return bySymbol[symbols[i]] > bySymbol[symbols[j]] })
+	if len(symbols) > 10 {
+		symbols = symbols[:10]
+	}
+
+	buckets := make([]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		buckets = append(buckets, map[string]interface{}{
+			"key":           symbol,
+			"doc_count":     counts[symbol],
+			"by_market_cap": map[string]interface{}{"value": bySymbol[symbol]},
+		})
+	}
+
+	return map[string]interface{}{
+		"avg_price":        map[string]interface{}{"value": avgPrice},
+		"total_market_cap": map[string]interface{}{"value": totalMarketCap},
+		"top_tokens":       map[string]interface{}{"buckets": buckets},
+	}, nil
+}
+
+func (m *MemoryStore) SaveHolding(ctx context.Context, holding models.Portfolio) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.holdings[holding.UserID] == nil {
+		m.holdings[holding.UserID] = make(map[string]models.Portfolio)
+	}
+	m.holdings[holding.UserID][holding.TokenID] = holding
+	return nil
+}
+
+func (m *MemoryStore) GetHolding(ctx context.Context, userID, tokenID string) (*models.Portfolio, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	holding, ok := m.holdings[userID][tokenID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &holding, nil
+}
+
+func (m *MemoryStore) GetHoldings(ctx context.Context, userID string) ([]models.Portfolio, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	holdings := make([]models.Portfolio, 0, len(m.holdings[userID]))
+	for _, holding := range m.holdings[userID] {
+		holdings = append(holdings, holding)
+	}
+	sort.Slice(holdings, func(i, j int) bool { return holdings[i].TokenID < holdings[j].TokenID })
+	return holdings, nil
+}
+
+func (m *MemoryStore) DeleteHolding(ctx context.Context, userID, tokenID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.holdings[userID], tokenID)
+	return nil
+}
+
+func (m *MemoryStore) SaveTransaction(ctx context.Context, tx *models.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx.ID = gocql.UUIDFromTime(tx.Timestamp).String()
+	transactions := append(m.transactions[tx.UserID], *tx)
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Timestamp.Before(transactions[j].Timestamp)
+	})
+	m.transactions[tx.UserID] = transactions
+	return nil
+}
+
+func (m *MemoryStore) GetTransactions(ctx context.Context, userID string) ([]models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transactions := make([]models.Transaction, len(m.transactions[userID]))
+	copy(transactions, m.transactions[userID])
+	return transactions, nil
+}
+
+func (m *MemoryStore) DeleteTransaction(ctx context.Context, userID, id string) error {
+	if _, err := gocql.ParseUUID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transactions := m.transactions[userID]
+	for i, tx := range transactions {
+		if tx.ID == id {
+			m.transactions[userID] = append(transactions[:i:i], transactions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) SaveRates(ctx context.Context, rates map[string]float64, updatedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for currency, rate := range rates {
+		m.rates[currency] = rate
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetRates(ctx context.Context) (map[string]float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rates := make(map[string]float64, len(m.rates))
+	for currency, rate := range m.rates {
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+func (m *MemoryStore) SaveBackfill(ctx context.Context, state models.BackfillState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.backfills[state.TokenID] = state
+	return nil
+}
+
+func (m *MemoryStore) GetBackfill(ctx context.Context, tokenID string) (*models.BackfillState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.backfills[tokenID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &state, nil
+}
+
+func (m *MemoryStore) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	if alert.ID == "" {
+		alert.ID = gocql.UUIDFromTime(alert.CreatedAt).String()
+	} else if _, err := gocql.ParseUUID(alert.ID); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alerts[alert.UserID] == nil {
+		m.alerts[alert.UserID] = make(map[string]models.Alert)
+	}
+	m.alerts[alert.UserID][alert.ID] = *alert
+	return nil
+}
+
+func (m *MemoryStore) UpdateAlertState(ctx context.Context, alert models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.alerts[alert.UserID][alert.ID]
+	if !ok {
+		return nil
+	}
+	stored.State = alert.State
+	stored.TriggeredAt = alert.TriggeredAt
+	stored.CooldownUntil = alert.CooldownUntil
+	m.alerts[alert.UserID][alert.ID] = stored
+	return nil
+}
+
+func (m *MemoryStore) GetAlert(ctx context.Context, userID, id string) (*models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	alert, ok := m.alerts[userID][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &alert, nil
+}
+
+func (m *MemoryStore) GetAlerts(ctx context.Context, userID string) ([]models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedAlerts(m.alerts[userID]), nil
+}
+
+func (m *MemoryStore) ListAlerts(ctx context.Context) ([]models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	alerts := make([]models.Alert, 0)
+	for _, userAlerts := range m.alerts {
+		alerts = append(alerts, sortedAlerts(userAlerts)...)
+	}
+	return alerts, nil
+}
+
+// sortedAlerts returns alerts in creation order, like the timeuuid clustering in ScyllaDB
+func sortedAlerts(byID map[string]models.Alert) []models.Alert {
+	alerts := make([]models.Alert, 0, len(byID))
+	for _, alert := range byID {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts
+}
+
+func (m *MemoryStore) DeleteAlert(ctx context.Context, userID, id string) error {
+	if _, err := gocql.ParseUUID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.alerts[userID], id)
+	return nil
+}
+
+func (m *MemoryStore) SaveAlertEvent(ctx context.Context, event models.AlertEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alertEvents[event.UserID] = append(m.alertEvents[event.UserID], event)
+	return nil
+}
+
+func (m *MemoryStore) GetAlertEvents(ctx context.Context, userID string, limit int) ([]models.AlertEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.alertEvents[userID]
+	events := make([]models.AlertEvent, 0, min(limit, len(stored)))
+	for i := len(stored) - 1; i >= 0 && len(events) < limit; i-- {
+		events = append(events, stored[i])
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) SaveWebhook(ctx context.Context, hook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook.ID = gocql.UUIDFromTime(hook.CreatedAt).String()
+	if m.webhooks[hook.UserID] == nil {
+		m.webhooks[hook.UserID] = make(map[string]models.Webhook)
+	}
+	m.webhooks[hook.UserID][hook.ID] = *hook
+	return nil
+}
+
+func (m *MemoryStore) GetWebhook(ctx context.Context, userID, id string) (*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hook, ok := m.webhooks[userID][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &hook, nil
+}
+
+func (m *MemoryStore) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hooks := make([]models.Webhook, 0, len(m.webhooks[userID]))
+	for _, hook := range m.webhooks[userID] {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(ctx context.Context, userID, id string) error {
+	if _, err := gocql.ParseUUID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.webhooks[userID], id)
+	return nil
+}
+
+func (m *MemoryStore) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if delivery.ID == "" {
+		delivery.ID = gocql.UUIDFromTime(delivery.CreatedAt).String()
+	} else if _, err := gocql.ParseUUID(delivery.ID); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deliveries[delivery.UserID] == nil {
+		m.deliveries[delivery.UserID] = make(map[string]models.WebhookDelivery)
+	}
+	m.deliveries[delivery.UserID][delivery.ID] = *delivery
+	return nil
+}
+
+func (m *MemoryStore) GetDelivery(ctx context.Context, userID, id string) (*models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	delivery, ok := m.deliveries[userID][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &delivery, nil
+}
+
+func (m *MemoryStore) GetDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := make([]models.WebhookDelivery, 0, len(m.deliveries[userID]))
+	for _, delivery := range m.deliveries[userID] {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	return deliveries[:min(limit, len(deliveries))], nil
+}
+
+func (m *MemoryStore) PendingDeliveries(ctx context.Context, t time.Time, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for _, userDeliveries := range m.deliveries {
+		for _, delivery := range userDeliveries {
+			if delivery.Status == models.DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(t) {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt)
+	})
+	return deliveries[:min(limit, len(deliveries))], nil
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.Username]; ok {
+		return ErrConflict
+	}
+	m.users[user.Username] = user
+	return nil
+}
+
+func (m *MemoryStore) GetUser(ctx context.Context, username string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *MemoryStore) SaveAPIKey(ctx context.Context, key *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key.ID = gocql.UUIDFromTime(key.CreatedAt).String()
+	if m.apiKeys[key.UserID] == nil {
+		m.apiKeys[key.UserID] = make(map[string]models.APIKey)
+	}
+	m.apiKeys[key.UserID][key.ID] = *key
+	m.apiKeyHashes[key.KeyHash] = *key
+	return nil
+}
+
+func (m *MemoryStore) GetAPIKey(ctx context.Context, userID, id string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[userID][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+func (m *MemoryStore) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(m.apiKeys[userID]))
+	for _, key := range m.apiKeys[userID] {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (m *MemoryStore) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	indexed, ok := m.apiKeyHashes[keyHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key := m.apiKeys[indexed.UserID][indexed.ID]
+	return &key, nil
+}
+
+func (m *MemoryStore) TouchAPIKey(ctx context.Context, userID, id string, t time.Time) error {
+	return m.updateAPIKey(userID, id, func(key *models.APIKey) { key.LastUsedAt = &t })
+}
+
+func (m *MemoryStore) RevokeAPIKey(ctx context.Context, userID, id string, t time.Time) error {
+	return m.updateAPIKey(userID, id, func(key *models.APIKey) { key.RevokedAt = &t })
+}
+
+func (m *MemoryStore) updateAPIKey(userID, id string, update func(*models.APIKey)) error {
+	if _, err := gocql.ParseUUID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[userID][id]
+	if !ok {
+		return nil
+	}
+	update(&key)
+	m.apiKeys[userID][id] = key
+	return nil
+}
@@ -0,0 +1,465 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// scyllaMigration is one versioned schema change. Up steps are idempotent
+// (IF NOT EXISTS, ensureColumn), so keyspaces created before migrations were
+// tracked are adopted by recording the migrations they already contain.
+type scyllaMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *ScyllaDB) error
+	Down    func(ctx context.Context, db *ScyllaDB) error
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// scyllaMigrations must only ever be appended to
+var scyllaMigrations = []scyllaMigration{
+	{
+		Version: 1,
+		Name:    "create_tokens_and_holdings",
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS tokens (
+            id text PRIMARY KEY,
+            symbol text,
+            name text,
+            current_price double,
+            market_cap double,
+            volume_24h double,
+            updated_at timestamp
+        )
+    `, `
+        CREATE TABLE IF NOT EXISTS portfolio_holdings (
+            user_id text,
+            token_id text,
+            amount double,
+            buy_price double,
+            buy_date timestamp,
+            PRIMARY KEY (user_id, token_id)
+        )
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS portfolio_holdings`, `DROP TABLE IF EXISTS tokens`),
+	},
+	{
+		Version: 2,
+		Name:    "create_transactions",
+		// timeuuid ids keep each user's ledger time-ordered
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS transactions (
+            user_id text,
+            id timeuuid,
+            timestamp timestamp,
+            token_id text,
+            type text,
+            amount double,
+            price double,
+            fee double,
+            wallet text,
+            to_wallet text,
+            note text,
+            PRIMARY KEY (user_id, id)
+        ) WITH CLUSTERING ORDER BY (id ASC)
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS transactions`),
+	},
+	{
+		Version: 3,
+		Name:    "add_token_sources",
+		Up: func(ctx context.Context, db *ScyllaDB) error {
+			return db.ensureColumn("tokens", "sources", "list<text>")
+		},
+		Down: func(ctx context.Context, db *ScyllaDB) error {
+			return db.dropColumn("tokens", "sources")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_quotes_and_fx_rates",
+		Up: func(ctx context.Context, db *ScyllaDB) error {
+			if err := db.ensureColumn("tokens", "quotes", "map<text, double>"); err != nil {
+				return err
+			}
+			// Units of each currency per US dollar
+			return execAll(`
+        CREATE TABLE IF NOT EXISTS fx_rates (
+            currency text PRIMARY KEY,
+            rate double,
+            updated_at timestamp
+        )
+    `)(ctx, db)
+		},
+		Down: func(ctx context.Context, db *ScyllaDB) error {
+			if err := execAll(`DROP TABLE IF EXISTS fx_rates`)(ctx, db); err != nil {
+				return err
+			}
+			return db.dropColumn("tokens", "quotes")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_backfill_state",
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS backfill_state (
+            token_id text PRIMARY KEY,
+            from_time timestamp,
+            to_time timestamp,
+            resume_at timestamp,
+            points int,
+            status text,
+            error text,
+            updated_at timestamp
+        )
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS backfill_state`),
+	},
+	{
+		Version: 6,
+		Name:    "create_price_candles",
+		// One partition per token and interval
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS price_candles (
+            token_id text,
+            resolution text,
+            bucket_start timestamp,
+            open double,
+            high double,
+            low double,
+            close double,
+            count int,
+            open_time timestamp,
+            close_time timestamp,
+            PRIMARY KEY ((token_id, resolution), bucket_start)
+        ) WITH CLUSTERING ORDER BY (bucket_start ASC)
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS price_candles`),
+	},
+	{
+		Version: 7,
+		Name:    "bucket_price_history_by_month",
+		Up: func(ctx context.Context, db *ScyllaDB) error {
+			// Earlier versions kept each token's ticks in a single unbounded partition
+			hasLegacyPrices, err := db.tableExists("price_history")
+			if err != nil {
+				return err
+			}
+
+			if err := execAll(`
+        CREATE TABLE IF NOT EXISTS price_history_by_month (
+            token_id text,
+            bucket text,
+            timestamp timestamp,
+            price double,
+            quotes map<text, double>,
+            PRIMARY KEY ((token_id, bucket), timestamp)
+        ) WITH CLUSTERING ORDER BY (timestamp DESC)
+    `, `
+        CREATE TABLE IF NOT EXISTS price_history_buckets (
+            token_id text,
+            bucket text,
+            PRIMARY KEY (token_id, bucket)
+        ) WITH CLUSTERING ORDER BY (bucket DESC)
+    `)(ctx, db); err != nil {
+				return err
+			}
+
+			// The copy runs whenever the legacy table exists, so a rerun after an
+			// interrupted copy finishes it rather than skipping the rest
+			if hasLegacyPrices {
+				return db.copyLegacyPrices(ctx)
+			}
+			return nil
+		},
+		// The legacy price_history table, if any, is left untouched by both directions
+		Down: func(ctx context.Context, db *ScyllaDB) error {
+			db.buckets.Clear()
+			return execAll(`DROP TABLE IF EXISTS price_history_buckets`,
+				`DROP TABLE IF EXISTS price_history_by_month`)(ctx, db)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create_alerts",
+		// Fired alerts are kept per user, newest first
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS alerts (
+            user_id text,
+            id timeuuid,
+            type text,
+            token_id text,
+            threshold double,
+            time_window text,
+            cooldown text,
+            state text,
+            triggered_at timestamp,
+            cooldown_until timestamp,
+            created_at timestamp,
+            PRIMARY KEY (user_id, id)
+        ) WITH CLUSTERING ORDER BY (id ASC)
+    `, `
+        CREATE TABLE IF NOT EXISTS alert_events (
+            user_id text,
+            triggered_at timestamp,
+            alert_id timeuuid,
+            type text,
+            token_id text,
+            threshold double,
+            observed_value double,
+            message text,
+            PRIMARY KEY (user_id, triggered_at, alert_id)
+        ) WITH CLUSTERING ORDER BY (triggered_at DESC, alert_id ASC)
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS alert_events`, `DROP TABLE IF EXISTS alerts`),
+	},
+	{
+		Version: 9,
+		Name:    "create_webhooks",
+		// Queued deliveries are few and short-lived, so they share one partition
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS webhooks (
+            user_id text,
+            id timeuuid,
+            url text,
+            events list<text>,
+            secret text,
+            created_at timestamp,
+            PRIMARY KEY (user_id, id)
+        ) WITH CLUSTERING ORDER BY (id ASC)
+    `, `
+        CREATE TABLE IF NOT EXISTS webhook_deliveries (
+            user_id text,
+            id timeuuid,
+            webhook_id timeuuid,
+            event text,
+            url text,
+            payload text,
+            status text,
+            attempts int,
+            response_code int,
+            error text,
+            next_attempt_at timestamp,
+            created_at timestamp,
+            updated_at timestamp,
+            PRIMARY KEY (user_id, id)
+        ) WITH CLUSTERING ORDER BY (id DESC)
+    `, `
+        CREATE TABLE IF NOT EXISTS webhook_queue (
+            shard int,
+            id timeuuid,
+            user_id text,
+            next_attempt_at timestamp,
+            PRIMARY KEY (shard, id)
+        ) WITH CLUSTERING ORDER BY (id ASC)
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS webhook_queue`, `DROP TABLE IF EXISTS webhook_deliveries`,
+			`DROP TABLE IF EXISTS webhooks`),
+	},
+	{
+		Version: 10,
+		Name:    "create_users",
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS users (
+            username text PRIMARY KEY,
+            password_hash text,
+            role text,
+            created_at timestamp
+        )
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS users`),
+	},
+	{
+		Version: 11,
+		Name:    "create_api_keys",
+		Up: execAll(`
+        CREATE TABLE IF NOT EXISTS api_keys (
+            user_id text,
+            id timeuuid,
+            name text,
+            prefix text,
+            key_hash text,
+            scopes set<text>,
+            created_at timestamp,
+            last_used_at timestamp,
+            revoked_at timestamp,
+            PRIMARY KEY (user_id, id)
+        ) WITH CLUSTERING ORDER BY (id ASC)
+    `, `
+        CREATE TABLE IF NOT EXISTS api_keys_by_hash (
+            key_hash text PRIMARY KEY,
+            user_id text,
+            id timeuuid
+        )
+    `),
+		Down: execAll(`DROP TABLE IF EXISTS api_keys_by_hash`, `DROP TABLE IF EXISTS api_keys`),
+	},
+}
+
+// LatestScyllaVersion is the version of the newest migration
+func LatestScyllaVersion() int {
+	return scyllaMigrations[len(scyllaMigrations)-1].Version
+}
+
+// execAll returns a migration step running each statement in order
+func execAll(statements ...string) func(ctx context.Context, db *ScyllaDB) error {
+	return func(ctx context.Context, db *ScyllaDB) error {
+		for _, stmt := range statements {
+			if err := db.Session.Query(stmt).WithContext(ctx).Exec(); err != nil {
+				return fmt.Errorf("failed to execute %q: %w", compact(stmt), err)
+			}
+		}
+		return nil
+	}
+}
+
+// compact collapses the whitespace of a statement for error messages
+func compact(stmt string) string {
+	return strings.Join(strings.Fields(stmt), " ")
+}
+
+func (db *ScyllaDB) ensureMigrationsTable(ctx context.Context) error {
+	return execAll(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version int PRIMARY KEY,
+            name text,
+            applied_at timestamp
+        )
+    `)(ctx, db)
+}
+
+// appliedMigrations returns the applied time of each applied version
+func (db *ScyllaDB) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	iter := db.Session.Query(`SELECT version, applied_at FROM schema_migrations`).WithContext(ctx).Iter()
+
+	applied := make(map[int]time.Time)
+	var version int
+	var appliedAt time.Time
+	for iter.Scan(&version, &appliedAt) {
+		applied[version] = appliedAt
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// Migrations lists every known migration with its applied time
+func (db *ScyllaDB) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(scyllaMigrations))
+	for _, m := range scyllaMigrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Migrate applies pending migrations up to target, or rolls back applied
+// migrations above target, newest first. Each migration is recorded as soon
+// as it succeeds, so a failed run can simply be repeated.
+func (db *ScyllaDB) Migrate(ctx context.Context, target int) error {
+	if target < 0 || target > LatestScyllaVersion() {
+		return fmt.Errorf("unknown schema version %d (latest is %d)", target, LatestScyllaVersion())
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range scyllaMigrations {
+		if _, ok := applied[m.Version]; ok || m.Version > target {
+			continue
+		}
+
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d %s failed: %w", m.Version, m.Name, err)
+		}
+
+		query := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+		if err := db.Session.Query(query, m.Version, m.Name, time.Now()).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+		log.Printf("⬆️  Applied migration %d %s", m.Version, m.Name)
+	}
+
+	for i := len(scyllaMigrations) - 1; i >= 0; i-- {
+		m := scyllaMigrations[i]
+		if _, ok := applied[m.Version]; !ok || m.Version <= target {
+			continue
+		}
+
+		if err := m.Down(ctx, db); err != nil {
+			return fmt.Errorf("rollback of migration %d %s failed: %w", m.Version, m.Name, err)
+		}
+
+		query := `DELETE FROM schema_migrations WHERE version = ?`
+		if err := db.Session.Query(query, m.Version).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to record rollback of migration %d: %w", m.Version, err)
+		}
+		log.Printf("⬇️  Rolled back migration %d %s", m.Version, m.Name)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0
+func (db *ScyllaDB) SchemaVersion(ctx context.Context) (int, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// dropColumn removes a column from a table if it is present
+func (db *ScyllaDB) dropColumn(table, column string) error {
+	query := `SELECT column_name FROM system_schema.columns 
+              WHERE keyspace_name = ? AND table_name = ? AND column_name = ?`
+
+	var name string
+	err := db.Session.Query(query, db.Config.Keyspace, table, column).Scan(&name)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+
+	alter := fmt.Sprintf(`ALTER TABLE %s DROP %s`, table, column)
+	if err := db.Session.Query(alter).Exec(); err != nil {
+		return fmt.Errorf("failed to drop %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
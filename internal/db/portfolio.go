@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// ErrNotFound is returned when a requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// SaveHolding inserts or replaces a user's position in a token
+func (db *ScyllaDB) SaveHolding(ctx context.Context, holding models.Portfolio) error {
+	query := `INSERT INTO portfolio_holdings (user_id, token_id, amount, buy_price, buy_date) 
+              VALUES (?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		holding.UserID, holding.TokenID, holding.Amount,
+		holding.BuyPrice, holding.BuyDate).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save holding: %w", err)
+	}
+
+	return nil
+}
+
+// GetHolding returns a single position of a user
+func (db *ScyllaDB) GetHolding(ctx context.Context, userID, tokenID string) (*models.Portfolio, error) {
+	query := `SELECT user_id, token_id, amount, buy_price, buy_date 
+              FROM portfolio_holdings WHERE user_id = ? AND token_id = ?`
+
+	var holding models.Portfolio
+	if err := db.Session.Query(query, userID, tokenID).WithContext(ctx).Scan(
+		&holding.UserID, &holding.TokenID, &holding.Amount,
+		&holding.BuyPrice, &holding.BuyDate); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get holding: %w", err)
+	}
+
+	return &holding, nil
+}
+
+// GetHoldings returns all positions of a user
+func (db *ScyllaDB) GetHoldings(ctx context.Context, userID string) ([]models.Portfolio, error) {
+	query := `SELECT user_id, token_id, amount, buy_price, buy_date 
+              FROM portfolio_holdings WHERE user_id = ?`
+
+	iter := db.Session.Query(query, userID).WithContext(ctx).Iter()
+
+	holdings := make([]models.Portfolio, 0)
+	var holding models.Portfolio
+
+	for iter.Scan(&holding.UserID, &holding.TokenID, &holding.Amount,
+		&holding.BuyPrice, &holding.BuyDate) {
+		holdings = append(holdings, holding)
+		holding = models.Portfolio{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch holdings: %w", err)
+	}
+
+	return holdings, nil
+}
+
+// DeleteHolding removes a user's position in a token
+func (db *ScyllaDB) DeleteHolding(ctx context.Context, userID, tokenID string) error {
+	query := `DELETE FROM portfolio_holdings WHERE user_id = ? AND token_id = ?`
+
+	if err := db.Session.Query(query, userID, tokenID).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to delete holding: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,520 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Price ticks are partitioned by token and UTC calendar month, so no partition
+// grows without bound. price_history_buckets lists the months that hold ticks
+// of each token, which lets reads walk across partitions.
+const priceBucketLayout = "2006-01"
+
+// ErrInvalidCursor is returned for a page cursor that was not issued by the store
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// priceBatchSize bounds the statements of one unlogged batch. All statements
+// of a batch target the same partition, so it is applied as a single mutation.
+const priceBatchSize = 100
+
+func priceBucket(t time.Time) string {
+	return t.UTC().Format(priceBucketLayout)
+}
+
+// ttlSeconds converts a retention to a CQL TTL, where 0 disables expiry
+func ttlSeconds(retention time.Duration) int {
+	return int(retention / time.Second)
+}
+
+// SavePrice appends a point to a token's price history
+func (db *ScyllaDB) SavePrice(ctx context.Context, point models.PriceHistory) error {
+	query := `INSERT INTO price_history_by_month (token_id, bucket, timestamp, price, quotes) 
+              VALUES (?, ?, ?, ?, ?) USING TTL ?`
+
+	bucket := priceBucket(point.Timestamp)
+	if err := db.registerBucket(ctx, point.TokenID, bucket); err != nil {
+		return err
+	}
+
+	if err := db.Session.Query(query,
+		point.TokenID, bucket, point.Timestamp, point.Price, point.Quotes,
+		ttlSeconds(db.Retention.Raw)).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save price: %w", err)
+	}
+
+	return nil
+}
+
+// SavePrices writes points in unlogged batches grouped by partition
+func (db *ScyllaDB) SavePrices(ctx context.Context, points []models.PriceHistory) error {
+	ttl := ttlSeconds(db.Retention.Raw)
+	return db.savePrices(ctx, points, func(models.PriceHistory) int { return ttl })
+}
+
+// savePrices writes points in unlogged batches grouped by partition, each
+// with the TTL returned for it
+func (db *ScyllaDB) savePrices(ctx context.Context, points []models.PriceHistory, ttl func(models.PriceHistory) int) error {
+	query := `INSERT INTO price_history_by_month (token_id, bucket, timestamp, price, quotes) 
+              VALUES (?, ?, ?, ?, ?) USING TTL ?`
+
+	type partition struct{ tokenID, bucket string }
+	order := make([]partition, 0)
+	byPartition := make(map[partition][]models.PriceHistory)
+	for _, point := range points {
+		key := partition{point.TokenID, priceBucket(point.Timestamp)}
+		if _, ok := byPartition[key]; !ok {
+			order = append(order, key)
+		}
+		byPartition[key] = append(byPartition[key], point)
+	}
+
+	for _, key := range order {
+		if err := db.registerBucket(ctx, key.tokenID, key.bucket); err != nil {
+			return err
+		}
+
+		partPoints := byPartition[key]
+		for start := 0; start < len(partPoints); start += priceBatchSize {
+			batch := db.Session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+			for _, point := range partPoints[start:min(start+priceBatchSize, len(partPoints))] {
+				batch.Query(query, point.TokenID, key.bucket, point.Timestamp, point.Price, point.Quotes, ttl(point))
+			}
+
+			if err := db.Session.ExecuteBatch(batch); err != nil {
+				return fmt.Errorf("failed to save prices of %s: %w", key.tokenID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// registerBucket records that a token has ticks in a month. Buckets already
+// registered by this process are skipped to keep writes to one per tick.
+func (db *ScyllaDB) registerBucket(ctx context.Context, tokenID, bucket string) error {
+	key := tokenID + "/" + bucket
+	if _, ok := db.buckets.Load(key); ok {
+		return nil
+	}
+
+	query := `INSERT INTO price_history_buckets (token_id, bucket) VALUES (?, ?)`
+	if err := db.Session.Query(query, tokenID, bucket).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to register price bucket: %w", err)
+	}
+
+	db.buckets.Store(key, true)
+	return nil
+}
+
+// listBuckets returns the months holding ticks of a token between from and
+// to, newest first unless ascending is set. Zero times leave the range open.
+func (db *ScyllaDB) listBuckets(ctx context.Context, tokenID string, from, to time.Time, ascending bool) ([]string, error) {
+	query := `SELECT bucket FROM price_history_buckets WHERE token_id = ?`
+	args := []interface{}{tokenID}
+	if !from.IsZero() {
+		query += ` AND bucket >= ?`
+		args = append(args, priceBucket(from))
+	}
+	if !to.IsZero() {
+		query += ` AND bucket <= ?`
+		args = append(args, priceBucket(to))
+	}
+	if ascending {
+		query += ` ORDER BY bucket ASC`
+	}
+
+	iter := db.Session.Query(query, args...).WithContext(ctx).Iter()
+
+	buckets := make([]string, 0)
+	var bucket string
+	for iter.Scan(&bucket) {
+		buckets = append(buckets, bucket)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to list price buckets: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// priceCursor locates the next page: a bucket and the driver's paging state within it
+type priceCursor struct {
+	Bucket string `json:"b"`
+	State  []byte `json:"s,omitempty"`
+}
+
+func encodePriceCursor(bucket string, state []byte) string {
+	raw, _ := json.Marshal(priceCursor{Bucket: bucket, State: state})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePriceCursor(cursor string) (priceCursor, error) {
+	var c priceCursor
+	if cursor == "" {
+		return c, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(raw, &c) != nil || c.Bucket == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// GetPriceHistory returns a page of a token's points, newest first, walking
+// the month buckets from the newest. The cursor records the bucket and the
+// driver's paging state, so pages resume server side.
+func (db *ScyllaDB) GetPriceHistory(ctx context.Context, tokenID string, q PriceQuery) ([]models.PriceHistory, string, error) {
+	cursor, err := decodePriceCursor(q.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buckets, err := db.listBuckets(ctx, tokenID, q.From, q.To, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `SELECT token_id, timestamp, price, quotes FROM price_history_by_month 
+              WHERE token_id = ? AND bucket = ?`
+	if !q.From.IsZero() {
+		query += ` AND timestamp >= ?`
+	}
+	if !q.To.IsZero() {
+		query += ` AND timestamp <= ?`
+	}
+
+	points := make([]models.PriceHistory, 0, q.Limit)
+	for i, bucket := range buckets {
+		// Buckets newer than the cursor were returned by earlier pages
+		if cursor.Bucket != "" && bucket > cursor.Bucket {
+			continue
+		}
+
+		args := []interface{}{tokenID, bucket}
+		if !q.From.IsZero() {
+			args = append(args, q.From)
+		}
+		if !q.To.IsZero() {
+			args = append(args, q.To)
+		}
+
+		var state []byte
+		if bucket == cursor.Bucket {
+			state = cursor.State
+		}
+
+		// Pages may come back short, so read until the bucket or the page is done
+		for {
+			iter := db.Session.Query(query, args...).WithContext(ctx).
+				PageSize(q.Limit - len(points)).PageState(state).Iter()
+			state = iter.PageState()
+
+			page, err := db.scanPrices(iter)
+			if err != nil {
+				return nil, "", err
+			}
+			points = append(points, page...)
+
+			if len(state) == 0 {
+				break
+			}
+			if len(points) >= q.Limit {
+				return points, encodePriceCursor(bucket, state), nil
+			}
+		}
+
+		if len(points) >= q.Limit {
+			if i+1 < len(buckets) {
+				return points, encodePriceCursor(buckets[i+1], nil), nil
+			}
+			return points, "", nil
+		}
+	}
+
+	return points, "", nil
+}
+
+// GetPointAt returns the latest stored point of a token at or before t,
+// falling back to the rollups when the raw ticks have expired
+func (db *ScyllaDB) GetPointAt(ctx context.Context, tokenID string, t time.Time) (*models.PriceHistory, error) {
+	// The bucket registry never expires, so bound it by the raw retention
+	// rather than walking months whose ticks are long gone
+	var from time.Time
+	if db.Retention.Raw > 0 {
+		from = t.Add(-db.Retention.Raw)
+	}
+	buckets, err := db.listBuckets(ctx, tokenID, from, t, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT token_id, timestamp, price, quotes FROM price_history_by_month 
+              WHERE token_id = ? AND bucket = ? AND timestamp <= ? LIMIT 1`
+
+	// Newest first, so the first bucket with a tick at or before t holds the answer
+	for _, bucket := range buckets {
+		var point models.PriceHistory
+		err := db.Session.Query(query, tokenID, bucket, t).WithContext(ctx).
+			Scan(&point.TokenID, &point.Timestamp, &point.Price, &point.Quotes)
+		if err == nil {
+			return &point, nil
+		}
+		if !errors.Is(err, gocql.ErrNotFound) {
+			return nil, fmt.Errorf("failed to fetch price: %w", err)
+		}
+	}
+
+	for _, interval := range rollupIntervals {
+		candles, err := db.latestCandles(ctx, tokenID, interval, t)
+		if err != nil {
+			return nil, err
+		}
+		if point, ok := candlePointAt(tokenID, candles, t); ok {
+			return point, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetPriceRange returns the stored prices of a token between from and to,
+// oldest first. The part of the range older than the raw ticks is read from
+// the rollups.
+func (db *ScyllaDB) GetPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error) {
+	points, err := db.GetRawPriceRange(ctx, tokenID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	end := to
+	if len(points) > 0 {
+		end = points[0].Timestamp
+	}
+	rollups, err := rollupRange(ctx, db, tokenID, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rollups, points...), nil
+}
+
+// GetRawPriceRange returns the raw ticks of a token between from and to, oldest first
+func (db *ScyllaDB) GetRawPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error) {
+	buckets, err := db.listBuckets(ctx, tokenID, from, to, true)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT token_id, timestamp, price, quotes FROM price_history_by_month 
+              WHERE token_id = ? AND bucket = ? AND timestamp >= ? AND timestamp <= ? ORDER BY timestamp ASC`
+
+	points := make([]models.PriceHistory, 0)
+	for _, bucket := range buckets {
+		page, err := db.scanPrices(db.Session.Query(query, tokenID, bucket, from, to).WithContext(ctx).Iter())
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, page...)
+	}
+
+	return points, nil
+}
+
+func (db *ScyllaDB) scanPrices(iter *gocql.Iter) ([]models.PriceHistory, error) {
+	points := make([]models.PriceHistory, 0)
+	var point models.PriceHistory
+
+	for iter.Scan(&point.TokenID, &point.Timestamp, &point.Price, &point.Quotes) {
+		points = append(points, point)
+		point = models.PriceHistory{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch price history: %w", err)
+	}
+
+	return points, nil
+}
+
+// copyLegacyPrices copies the ticks of the unbucketed price_history table,
+// written by earlier versions, into the month buckets. Ticks keep the TTL
+// they had left; those past the raw retention are only rolled into the 1h
+// and 1d candles. Every write is an upsert computed from the legacy table
+// alone, so a copy that was interrupted is completed by running it again.
+func (db *ScyllaDB) copyLegacyPrices(ctx context.Context) error {
+	if err := db.ensureColumn("price_history", "quotes", "map<text, double>"); err != nil {
+		return err
+	}
+
+	query := `SELECT token_id, timestamp, price, quotes FROM price_history`
+	iter := db.Session.Query(query).WithContext(ctx).Iter()
+
+	cp := newLegacyPriceCopy(db.Retention, time.Now())
+	var point models.PriceHistory
+	for iter.Scan(&point.TokenID, &point.Timestamp, &point.Price, &point.Quotes) {
+		cp.add(point)
+		point = models.PriceHistory{} // Reset for next iteration
+
+		if len(cp.ticks) == priceBatchSize {
+			if err := db.savePrices(ctx, cp.takeTicks(), cp.tickTTL); err != nil {
+				iter.Close()
+				return err
+			}
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("failed to read legacy price history: %w", err)
+	}
+	if err := db.savePrices(ctx, cp.takeTicks(), cp.tickTTL); err != nil {
+		return err
+	}
+
+	for _, series := range cp.rollups() {
+		ttl := func(c models.Candle) int { return cp.candleTTL(series.interval, c) }
+		if err := db.saveCandles(ctx, series.tokenID, series.interval, series.candles, ttl); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Copied %d prices from price_history into monthly buckets, rolled %d expired ones into candles",
+		cp.copied, cp.expired)
+	return nil
+}
+
+// remainingTTL returns the CQL TTL left to a row stamped at ts under a
+// retention, or false once it has expired. A zero retention never expires.
+func remainingTTL(retention time.Duration, ts, now time.Time) (int, bool) {
+	if retention == 0 {
+		return 0, true
+	}
+	left := ttlSeconds(retention - now.Sub(ts))
+	if left <= 0 {
+		return 0, false
+	}
+	return left, true
+}
+
+// legacyPriceCopy sorts the legacy ticks as they are read: ticks within the
+// raw retention are buffered for writing, older ones are merged into the
+// rollup candles of their hour and day
+type legacyPriceCopy struct {
+	retention config.RetentionConfig
+	now       time.Time
+	ticks     []models.PriceHistory
+	candles   map[legacyCandleKey]*models.Candle
+	copied    int
+	expired   int
+}
+
+type legacyCandleKey struct {
+	tokenID  string
+	interval string
+	start    int64
+}
+
+// candleSeries is the candles of one token and interval, oldest first
+type candleSeries struct {
+	tokenID  string
+	interval string
+	candles  []models.Candle
+}
+
+func newLegacyPriceCopy(retention config.RetentionConfig, now time.Time) *legacyPriceCopy {
+	return &legacyPriceCopy{
+		retention: retention,
+		now:       now,
+		ticks:     make([]models.PriceHistory, 0, priceBatchSize),
+		candles:   make(map[legacyCandleKey]*models.Candle),
+	}
+}
+
+func (c *legacyPriceCopy) add(point models.PriceHistory) {
+	if _, ok := remainingTTL(c.retention.Raw, point.Timestamp, c.now); ok {
+		c.ticks = append(c.ticks, point)
+		c.copied++
+		return
+	}
+
+	c.expired++
+	for _, interval := range rollupIntervals {
+		start := point.Timestamp.UTC().Truncate(rollupDurations[interval])
+		key := legacyCandleKey{point.TokenID, interval, start.Unix()}
+		candle, ok := c.candles[key]
+		if !ok {
+			c.candles[key] = &models.Candle{
+				Start: start, Open: point.Price, High: point.Price, Low: point.Price, Close: point.Price,
+				Count: 1, OpenTime: point.Timestamp, CloseTime: point.Timestamp,
+			}
+			continue
+		}
+
+		candle.High = max(candle.High, point.Price)
+		candle.Low = min(candle.Low, point.Price)
+		candle.Count++
+		if point.Timestamp.Before(candle.OpenTime) {
+			candle.Open = point.Price
+			candle.OpenTime = point.Timestamp
+		}
+		if !point.Timestamp.Before(candle.CloseTime) {
+			candle.Close = point.Price
+			candle.CloseTime = point.Timestamp
+		}
+	}
+}
+
+// takeTicks returns the buffered ticks and empties the buffer
+func (c *legacyPriceCopy) takeTicks() []models.PriceHistory {
+	ticks := c.ticks
+	c.ticks = make([]models.PriceHistory, 0, priceBatchSize)
+	return ticks
+}
+
+func (c *legacyPriceCopy) tickTTL(point models.PriceHistory) int {
+	ttl, _ := remainingTTL(c.retention.Raw, point.Timestamp, c.now)
+	return ttl
+}
+
+// candleTTL counts a candle's retention from its last tick, as live rollups do
+func (c *legacyPriceCopy) candleTTL(interval string, candle models.Candle) int {
+	ttl, _ := remainingTTL(c.retention.CandleTTL(interval), candle.CloseTime, c.now)
+	return ttl
+}
+
+// rollups returns the candles built from expired ticks that are still within
+// the retention of their interval, by token and interval
+func (c *legacyPriceCopy) rollups() []candleSeries {
+	keys := make([]legacyCandleKey, 0, len(c.candles))
+	for key, candle := range c.candles {
+		if _, ok := remainingTTL(c.retention.CandleTTL(key.interval), candle.CloseTime, c.now); ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b legacyCandleKey) int {
+		return cmp.Or(strings.Compare(a.tokenID, b.tokenID), strings.Compare(a.interval, b.interval), cmp.Compare(a.start, b.start))
+	})
+
+	series := make([]candleSeries, 0)
+	for _, key := range keys {
+		if n := len(series); n == 0 || series[n-1].tokenID != key.tokenID || series[n-1].interval != key.interval {
+			series = append(series, candleSeries{tokenID: key.tokenID, interval: key.interval})
+		}
+		last := &series[len(series)-1]
+		last.candles = append(last.candles, *c.candles[key])
+	}
+	return series
+}
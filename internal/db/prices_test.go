@@ -0,0 +1,169 @@
+package db
+
+import (
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"maps"
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var (
+	copyNow       = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	copyRetention = config.RetentionConfig{Raw: 90 * day, Candles1h: 2 * 365 * day}
+)
+
+// copiedPrices is what a legacy copy leaves in the new tables: the TTL of
+// each tick by timestamp and each candle with its TTL
+type copiedPrices struct {
+	ticks   map[time.Time]int
+	candles map[legacyCandleKey]copiedCandle
+}
+
+type copiedCandle struct {
+	candle models.Candle
+	ttl    int
+}
+
+func newCopiedPrices() *copiedPrices {
+	return &copiedPrices{ticks: make(map[time.Time]int), candles: make(map[legacyCandleKey]copiedCandle)}
+}
+
+// copyInto runs a copy of points the way copyLegacyPrices does, flushing
+// ticks every batch ticks. A positive failAfter stops the copy after that many
+// flushes, before the candles are written, as a crash would.
+func copyInto(dst *copiedPrices, points []models.PriceHistory, batch, failAfter int) {
+	cp := newLegacyPriceCopy(copyRetention, copyNow)
+	flushes := 0
+	flush := func() bool {
+		for _, tick := range cp.takeTicks() {
+			dst.ticks[tick.Timestamp] = cp.tickTTL(tick)
+		}
+		flushes++
+		return failAfter > 0 && flushes >= failAfter
+	}
+
+	for _, point := range points {
+		cp.add(point)
+		if len(cp.ticks) == batch && flush() {
+			return
+		}
+	}
+	if flush() {
+		return
+	}
+
+	for _, series := range cp.rollups() {
+		for _, c := range series.candles {
+			key := legacyCandleKey{series.tokenID, series.interval, c.Start.Unix()}
+			dst.candles[key] = copiedCandle{c, cp.candleTTL(series.interval, c)}
+		}
+	}
+}
+
+func legacyPoints() []models.PriceHistory {
+	at := func(ago time.Duration, price float64) models.PriceHistory {
+		return models.PriceHistory{TokenID: "bitcoin", Price: price, Timestamp: copyNow.Add(-ago)}
+	}
+	// Newest first, as the legacy table is clustered
+	return []models.PriceHistory{
+		at(10*day, 101),
+		at(10*day+time.Minute, 100),
+		at(100*day-2*time.Hour, 190),
+		at(100*day-10*time.Minute, 210),
+		at(100*day, 200),
+		at(3*365*day, 50),
+	}
+}
+
+func TestLegacyPriceCopy(t *testing.T) {
+	got := newCopiedPrices()
+	copyInto(got, legacyPoints(), priceBatchSize, 0)
+
+	// Ticks within the raw retention keep only the TTL they had left
+	wantTicks := map[time.Time]int{
+		copyNow.Add(-10 * day):             ttlSeconds(80 * day),
+		copyNow.Add(-10*day - time.Minute): ttlSeconds(80*day - time.Minute),
+	}
+	if !maps.Equal(got.ticks, wantTicks) {
+		t.Errorf("expected ticks %v, got %v", wantTicks, got.ticks)
+	}
+
+	// Expired ticks are rolled into candles; the 3 year old 1h candle is past its retention
+	expired := copyNow.Add(-100 * day)
+	hour := expired.Truncate(time.Hour)
+	laterHour := hour.Add(2 * time.Hour)
+	dayStart := expired.Truncate(day)
+	oldDay := copyNow.Add(-3 * 365 * day).Truncate(day)
+	want := map[legacyCandleKey]copiedCandle{
+		{"bitcoin", "1h", hour.Unix()}: {models.Candle{Start: hour, Open: 200, High: 210, Low: 200, Close: 210, Count: 2,
+			OpenTime: expired, CloseTime: expired.Add(10 * time.Minute)}, ttlSeconds(2*365*day - 100*day + 10*time.Minute)},
+		{"bitcoin", "1h", laterHour.Unix()}: {models.Candle{Start: laterHour, Open: 190, High: 190, Low: 190, Close: 190, Count: 1,
+			OpenTime: expired.Add(2 * time.Hour), CloseTime: expired.Add(2 * time.Hour)}, ttlSeconds(2*365*day - 100*day + 2*time.Hour)},
+		{"bitcoin", "1d", dayStart.Unix()}: {models.Candle{Start: dayStart, Open: 200, High: 210, Low: 190, Close: 190, Count: 3,
+			OpenTime: expired, CloseTime: expired.Add(2 * time.Hour)}, 0},
+		{"bitcoin", "1d", oldDay.Unix()}: {models.Candle{Start: oldDay, Open: 50, High: 50, Low: 50, Close: 50, Count: 1,
+			OpenTime: copyNow.Add(-3 * 365 * day), CloseTime: copyNow.Add(-3 * 365 * day)}, 0},
+	}
+	if len(got.candles) != len(want) {
+		t.Fatalf("expected %d candles, got %+v", len(want), got.candles)
+	}
+	for key, w := range want {
+		g, ok := got.candles[key]
+		if !ok || !equalCandles(g.candle, w.candle) || g.ttl != w.ttl {
+			t.Errorf("candle %+v: expected %+v, got %+v", key, w, g)
+		}
+	}
+}
+
+func TestLegacyPriceCopyResumesAfterInterruption(t *testing.T) {
+	// The first run dies after its first batch, once the bucketed table exists
+	// and holds part of the ticks; the migration then runs the copy again
+	resumed := newCopiedPrices()
+	copyInto(resumed, legacyPoints(), 1, 1)
+	if len(resumed.ticks) != 1 || len(resumed.candles) != 0 {
+		t.Fatalf("expected the interrupted copy to write one tick, got %+v", resumed)
+	}
+	copyInto(resumed, legacyPoints(), 1, 0)
+
+	clean := newCopiedPrices()
+	copyInto(clean, legacyPoints(), priceBatchSize, 0)
+
+	if !maps.Equal(resumed.ticks, clean.ticks) {
+		t.Errorf("expected ticks %v after the rerun, got %v", clean.ticks, resumed.ticks)
+	}
+	if !maps.EqualFunc(resumed.candles, clean.candles, func(a, b copiedCandle) bool {
+		return equalCandles(a.candle, b.candle) && a.ttl == b.ttl
+	}) {
+		t.Errorf("expected candles %+v after the rerun, got %+v", clean.candles, resumed.candles)
+	}
+}
+
+func TestRemainingTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+		age       time.Duration
+		ttl       int
+		ok        bool
+	}{
+		{"no retention never expires", 0, 10 * 365 * day, 0, true},
+		{"within retention", 90 * day, 30 * day, ttlSeconds(60 * day), true},
+		{"less than a second left", 90 * day, 90*day - time.Millisecond, 0, false},
+		{"past retention", 90 * day, 91 * day, 0, false},
+	}
+
+	for _, tt := range tests {
+		ttl, ok := remainingTTL(tt.retention, copyNow.Add(-tt.age), copyNow)
+		if ttl != tt.ttl || ok != tt.ok {
+			t.Errorf("%s: expected %d, %v, got %d, %v", tt.name, tt.ttl, tt.ok, ttl, ok)
+		}
+	}
+}
+
+func equalCandles(a, b models.Candle) bool {
+	return a.Start.Equal(b.Start) && a.Open == b.Open && a.High == b.High && a.Low == b.Low && a.Close == b.Close &&
+		a.Count == b.Count && a.OpenTime.Equal(b.OpenTime) && a.CloseTime.Equal(b.CloseTime)
+}
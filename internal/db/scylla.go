@@ -1,23 +1,33 @@
 package db
 
 import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"errors"
 	"fmt"
 	"log"
-	"time"
+	"sync"
 
 	"github.com/gocql/gocql"
 )
 
 type ScyllaDB struct {
-	Session *gocql.Session
+	Session   *gocql.Session
+	Config    config.ScyllaConfig
+	Retention config.RetentionConfig
+
+	// buckets caches the price buckets registered by this process
+	buckets sync.Map
 }
 
-func NewScyllaDB(hosts []string) (*ScyllaDB, error) {
+func NewScyllaDB(cfg config.ScyllaConfig, retention config.RetentionConfig) (*ScyllaDB, error) {
+	db := &ScyllaDB{Config: cfg, Retention: retention}
+
 	// First connection without keyspace to create it
-	cluster := gocql.NewCluster(hosts...)
-	cluster.Consistency = gocql.Quorum
-	cluster.Timeout = 10 * time.Second
-	cluster.ConnectTimeout = 10 * time.Second
+	cluster, err := db.newCluster("")
+	if err != nil {
+		return nil, err
+	}
 
 	session, err := cluster.CreateSession()
 	if err != nil {
@@ -25,66 +35,102 @@ func NewScyllaDB(hosts []string) (*ScyllaDB, error) {
 	}
 
 	log.Println("✅ Connected to ScyllaDB")
-	return &ScyllaDB{Session: session}, nil
+	db.Session = session
+	return db, nil
 }
 
+// newCluster builds a cluster config for the configured hosts and keyspace
+func (db *ScyllaDB) newCluster(keyspace string) (*gocql.ClusterConfig, error) {
+	consistency, err := gocql.ParseConsistencyWrapper(db.Config.Consistency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consistency: %w", err)
+	}
+
+	cluster := gocql.NewCluster(db.Config.Hosts...)
+	cluster.Keyspace = keyspace
+	cluster.Consistency = consistency
+	cluster.Timeout = db.Config.Timeout
+	cluster.ConnectTimeout = db.Config.Timeout
+	return cluster, nil
+}
+
+// InitSchema creates the keyspace and applies every pending migration
 func (db *ScyllaDB) InitSchema() error {
+	if err := db.InitKeyspace(); err != nil {
+		return err
+	}
+
+	// Apply pending migrations
+	if err := db.Migrate(context.Background(), LatestScyllaVersion()); err != nil {
+		return err
+	}
+
+	log.Println("✅ ScyllaDB schema initialized")
+	return nil
+}
+
+// InitKeyspace creates the keyspace and reconnects the session to it
+func (db *ScyllaDB) InitKeyspace() error {
 	// Create keyspace
-	keyspaceQuery := `
-        CREATE KEYSPACE IF NOT EXISTS crypto_tracker 
-        WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}
-    `
+	keyspaceQuery := fmt.Sprintf(`
+        CREATE KEYSPACE IF NOT EXISTS %s 
+        WITH replication = {'class': 'SimpleStrategy', 'replication_factor': %d}
+    `, db.Config.Keyspace, db.Config.ReplicationFactor)
 	if err := db.Session.Query(keyspaceQuery).Exec(); err != nil {
 		return fmt.Errorf("failed to create keyspace: %w", err)
 	}
-	log.Println("✅ Created keyspace crypto_tracker")
+	log.Printf("✅ Created keyspace %s", db.Config.Keyspace)
 
 	// Close initial session
 	db.Session.Close()
 
 	// Reconnect with keyspace
-	cluster := gocql.NewCluster("localhost:9042")
-	cluster.Keyspace = "crypto_tracker"
-	cluster.Consistency = gocql.Quorum
-	cluster.Timeout = 10 * time.Second
-	cluster.ConnectTimeout = 10 * time.Second
+	cluster, err := db.newCluster(db.Config.Keyspace)
+	if err != nil {
+		return err
+	}
 
 	session, err := cluster.CreateSession()
 	if err != nil {
 		return fmt.Errorf("failed to reconnect with keyspace: %w", err)
 	}
 	db.Session = session
+	return nil
+}
+
+// tableExists reports whether a table exists in the keyspace
+func (db *ScyllaDB) tableExists(table string) (bool, error) {
+	query := `SELECT table_name FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?`
 
-	// Create tokens table
-	tokensTable := `
-        CREATE TABLE IF NOT EXISTS tokens (
-            id text PRIMARY KEY,
-            symbol text,
-            name text,
-            current_price double,
-            market_cap double,
-            volume_24h double,
-            updated_at timestamp
-        )
-    `
-	if err := db.Session.Query(tokensTable).Exec(); err != nil {
-		return fmt.Errorf("failed to create tokens table: %w", err)
+	var name string
+	err := db.Session.Query(query, db.Config.Keyspace, table).Scan(&name)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
 	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	return true, nil
+}
+
+// ensureColumn adds a column to an existing table unless it is already present
+func (db *ScyllaDB) ensureColumn(table, column, columnType string) error {
+	query := `SELECT column_name FROM system_schema.columns 
+              WHERE keyspace_name = ? AND table_name = ? AND column_name = ?`
 
-	// Create price_history table
-	priceHistoryTable := `
-        CREATE TABLE IF NOT EXISTS price_history (
-            token_id text,
-            timestamp timestamp,
-            price double,
-            PRIMARY KEY (token_id, timestamp)
-        ) WITH CLUSTERING ORDER BY (timestamp DESC)
-    `
-	if err := db.Session.Query(priceHistoryTable).Exec(); err != nil {
-		return fmt.Errorf("failed to create price_history table: %w", err)
+	var name string
+	err := db.Session.Query(query, db.Config.Keyspace, table, column).Scan(&name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gocql.ErrNotFound) {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
 	}
 
-	log.Println("✅ ScyllaDB schema initialized")
+	alter := fmt.Sprintf(`ALTER TABLE %s ADD %s %s`, table, column, columnType)
+	if err := db.Session.Query(alter).Exec(); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"time"
+)
+
+// TokenStore persists the latest market data of each token
+type TokenStore interface {
+	SaveToken(ctx context.Context, token models.Token) error
+	GetToken(ctx context.Context, id string) (*models.Token, error)
+	ListTokens(ctx context.Context) ([]models.Token, error)
+	GetTokenPrices(ctx context.Context, tokenIDs []string) (map[string]float64, error)
+}
+
+// PriceQuery selects a page of a token's price history. A zero From or To
+// leaves that end of the range open; Cursor continues from a previous page
+// and is only valid with the same range.
+type PriceQuery struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Cursor string
+}
+
+// PriceHistoryStore persists the time series of token prices
+type PriceHistoryStore interface {
+	SavePrice(ctx context.Context, point models.PriceHistory) error
+	// SavePrices writes many points at once; points at existing timestamps are replaced
+	SavePrices(ctx context.Context, points []models.PriceHistory) error
+	// GetPriceHistory returns a page of points, newest first, and the cursor of
+	// the next page, which is empty after the last page
+	GetPriceHistory(ctx context.Context, tokenID string, q PriceQuery) ([]models.PriceHistory, string, error)
+	// GetPointAt returns the latest point at or before t, with its own
+	// timestamp and quotes. Once the raw ticks have expired it falls back to
+	// the closes of the 1h, then the 1d, candles, which carry no quotes.
+	GetPointAt(ctx context.Context, tokenID string, t time.Time) (*models.PriceHistory, error)
+	// GetPriceRange returns the points between from and to, oldest first.
+	// Like GetPointAt, the part of the range before the oldest raw tick is
+	// filled with candle closes.
+	GetPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error)
+	// GetRawPriceRange returns only the raw ticks between from and to, oldest
+	// first, without the candle fallback
+	GetRawPriceRange(ctx context.Context, tokenID string, from, to time.Time) ([]models.PriceHistory, error)
+}
+
+// CandleStore persists price candles rolled up per interval
+type CandleStore interface {
+	// SaveCandles inserts or replaces candles by their start
+	SaveCandles(ctx context.Context, tokenID, interval string, candles []models.Candle) error
+	GetCandle(ctx context.Context, tokenID, interval string, start time.Time) (*models.Candle, error)
+	// GetCandles returns the candles starting between from and to, oldest first
+	GetCandles(ctx context.Context, tokenID, interval string, from, to time.Time) ([]models.Candle, error)
+}
+
+// FXStore persists exchange rates, expressed in units of each currency per US dollar
+type FXStore interface {
+	SaveRates(ctx context.Context, rates map[string]float64, updatedAt time.Time) error
+	GetRates(ctx context.Context) (map[string]float64, error)
+}
+
+// BackfillStore persists the progress of historical price backfills
+type BackfillStore interface {
+	SaveBackfill(ctx context.Context, state models.BackfillState) error
+	GetBackfill(ctx context.Context, tokenID string) (*models.BackfillState, error)
+}
+
+// AlertStore persists users' alert rules and the history of fired alerts
+type AlertStore interface {
+	// SaveAlert inserts or replaces an alert, assigning an ID to new alerts
+	SaveAlert(ctx context.Context, alert *models.Alert) error
+	// UpdateAlertState writes the state of an existing alert; alerts deleted
+	// in the meantime are left deleted
+	UpdateAlertState(ctx context.Context, alert models.Alert) error
+	GetAlert(ctx context.Context, userID, id string) (*models.Alert, error)
+	GetAlerts(ctx context.Context, userID string) ([]models.Alert, error)
+	// ListAlerts returns the alerts of every user
+	ListAlerts(ctx context.Context) ([]models.Alert, error)
+	DeleteAlert(ctx context.Context, userID, id string) error
+	SaveAlertEvent(ctx context.Context, event models.AlertEvent) error
+	// GetAlertEvents returns up to limit of a user's fired alerts, newest first
+	GetAlertEvents(ctx context.Context, userID string, limit int) ([]models.AlertEvent, error)
+}
+
+// WebhookStore persists users' webhooks and the log of their deliveries
+type WebhookStore interface {
+	// SaveWebhook inserts a webhook, assigning its ID
+	SaveWebhook(ctx context.Context, hook *models.Webhook) error
+	GetWebhook(ctx context.Context, userID, id string) (*models.Webhook, error)
+	GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	// SaveDelivery inserts or replaces a delivery, assigning an ID to new
+	// deliveries. Pending deliveries are queued for PendingDeliveries.
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, userID, id string) (*models.WebhookDelivery, error)
+	// GetDeliveries returns up to limit of a user's deliveries, newest first
+	GetDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error)
+	// PendingDeliveries returns up to limit queued deliveries due at or before t, oldest first
+	PendingDeliveries(ctx context.Context, t time.Time, limit int) ([]models.WebhookDelivery, error)
+}
+
+// UserStore persists user accounts
+type UserStore interface {
+	// CreateUser inserts a user, returning ErrConflict when the username is taken
+	CreateUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, username string) (*models.User, error)
+}
+
+// APIKeyStore persists API keys, found by the hash of the key
+type APIKeyStore interface {
+	// SaveAPIKey inserts a key, assigning its ID
+	SaveAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, userID, id string) (*models.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, userID, id string, t time.Time) error
+	RevokeAPIKey(ctx context.Context, userID, id string, t time.Time) error
+}
+
+// SearchIndex provides full-text search and aggregations over tokens
+type SearchIndex interface {
+	IndexToken(ctx context.Context, token models.Token) error
+	SearchTokens(ctx context.Context, query string) ([]models.Token, error)
+	// Analytics returns the average price, total market cap and top tokens
+	// in the shape of an ElasticSearch aggregations response
+	Analytics(ctx context.Context) (map[string]interface{}, error)
+}
+
+// PortfolioStore persists users' holding snapshots
+type PortfolioStore interface {
+	SaveHolding(ctx context.Context, holding models.Portfolio) error
+	GetHolding(ctx context.Context, userID, tokenID string) (*models.Portfolio, error)
+	GetHoldings(ctx context.Context, userID string) ([]models.Portfolio, error)
+	DeleteHolding(ctx context.Context, userID, tokenID string) error
+}
+
+// TransactionStore persists users' transaction ledgers
+type TransactionStore interface {
+	SaveTransaction(ctx context.Context, tx *models.Transaction) error
+	GetTransactions(ctx context.Context, userID string) ([]models.Transaction, error)
+	DeleteTransaction(ctx context.Context, userID, id string) error
+}
+
+// Stores bundles every storage backend used by the handlers and services
+type Stores struct {
+	Tokens       TokenStore
+	Prices       PriceHistoryStore
+	Candles      CandleStore
+	Search       SearchIndex
+	Portfolios   PortfolioStore
+	Transactions TransactionStore
+	FX           FXStore
+	Backfills    BackfillStore
+	Alerts       AlertStore
+	Webhooks     WebhookStore
+	Users        UserStore
+	APIKeys      APIKeyStore
+}
+
+// NewClusterStores backs every store with ScyllaDB and search with ElasticSearch
+func NewClusterStores(scylla *ScyllaDB, es *ElasticSearch) Stores {
+	return Stores{
+		Tokens:       scylla,
+		Prices:       scylla,
+		Candles:      scylla,
+		Search:       es,
+		Portfolios:   scylla,
+		Transactions: scylla,
+		FX:           scylla,
+		Backfills:    scylla,
+		Alerts:       scylla,
+		Webhooks:     scylla,
+		Users:        scylla,
+		APIKeys:      scylla,
+	}
+}
+
+// NewMemoryStores backs every store with a single in-memory store
+func NewMemoryStores() Stores {
+	memory := NewMemoryStore()
+	return Stores{
+		Tokens:       memory,
+		Prices:       memory,
+		Candles:      memory,
+		Search:       memory,
+		Portfolios:   memory,
+		Transactions: memory,
+		FX:           memory,
+		Backfills:    memory,
+		Alerts:       memory,
+		Webhooks:     memory,
+		Users:        memory,
+		APIKeys:      memory,
+	}
+}
+
+var (
+	_ TokenStore        = (*ScyllaDB)(nil)
+	_ PriceHistoryStore = (*ScyllaDB)(nil)
+	_ CandleStore       = (*ScyllaDB)(nil)
+	_ PortfolioStore    = (*ScyllaDB)(nil)
+	_ TransactionStore  = (*ScyllaDB)(nil)
+	_ FXStore           = (*ScyllaDB)(nil)
+	_ BackfillStore     = (*ScyllaDB)(nil)
+	_ AlertStore        = (*ScyllaDB)(nil)
+	_ WebhookStore      = (*ScyllaDB)(nil)
+	_ UserStore         = (*ScyllaDB)(nil)
+	_ APIKeyStore       = (*ScyllaDB)(nil)
+	_ SearchIndex       = (*ElasticSearch)(nil)
+)
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// SaveToken inserts or replaces the market data of a token
+func (db *ScyllaDB) SaveToken(ctx context.Context, token models.Token) error {
+	query := `INSERT INTO tokens (id, symbol, name, current_price, market_cap, volume_24h, updated_at, sources, quotes) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		token.ID, token.Symbol, token.Name, token.CurrentPrice,
+		token.MarketCap, token.Volume24h, token.UpdatedAt, token.Sources, token.Quotes).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return nil
+}
+
+// GetToken returns a single token by ID
+func (db *ScyllaDB) GetToken(ctx context.Context, id string) (*models.Token, error) {
+	query := `SELECT id, symbol, name, current_price, market_cap, volume_24h, updated_at, sources, quotes 
+              FROM tokens WHERE id = ? LIMIT 1`
+
+	var token models.Token
+	if err := db.Session.Query(query, id).WithContext(ctx).Scan(
+		&token.ID, &token.Symbol, &token.Name, &token.CurrentPrice,
+		&token.MarketCap, &token.Volume24h, &token.UpdatedAt, &token.Sources, &token.Quotes); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListTokens returns every stored token
+func (db *ScyllaDB) ListTokens(ctx context.Context) ([]models.Token, error) {
+	query := `SELECT id, symbol, name, current_price, market_cap, volume_24h, updated_at, sources, quotes FROM tokens`
+
+	iter := db.Session.Query(query).WithContext(ctx).Iter()
+
+	tokens := make([]models.Token, 0)
+	var token models.Token
+
+	for iter.Scan(&token.ID, &token.Symbol, &token.Name, &token.CurrentPrice,
+		&token.MarketCap, &token.Volume24h, &token.UpdatedAt, &token.Sources, &token.Quotes) {
+		tokens = append(tokens, token)
+		token = models.Token{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// GetTokenPrices returns the current price of each requested token that exists
+func (db *ScyllaDB) GetTokenPrices(ctx context.Context, tokenIDs []string) (map[string]float64, error) {
+	prices := make(map[string]float64, len(tokenIDs))
+	if len(tokenIDs) == 0 {
+		return prices, nil
+	}
+
+	query := `SELECT id, current_price FROM tokens WHERE id IN ?`
+	iter := db.Session.Query(query, tokenIDs).WithContext(ctx).Iter()
+
+	var id string
+	var price float64
+	for iter.Scan(&id, &price) {
+		prices[id] = price
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch token prices: %w", err)
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// ErrInvalidID is returned when a transaction ID is not a valid timeuuid
+var ErrInvalidID = errors.New("invalid id")
+
+// SaveTransaction appends a transaction to a user's ledger.
+// The ID is derived from the transaction timestamp so the ledger stays time-ordered.
+func (db *ScyllaDB) SaveTransaction(ctx context.Context, tx *models.Transaction) error {
+	id := gocql.UUIDFromTime(tx.Timestamp)
+
+	query := `INSERT INTO transactions (user_id, id, timestamp, token_id, type, amount, price, fee, wallet, to_wallet, note) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		tx.UserID, id, tx.Timestamp, tx.TokenID, string(tx.Type), tx.Amount,
+		tx.Price, tx.Fee, tx.Wallet, tx.ToWallet, tx.Note).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save transaction: %w", err)
+	}
+
+	tx.ID = id.String()
+	return nil
+}
+
+// GetTransactions returns a user's whole ledger in chronological order
+func (db *ScyllaDB) GetTransactions(ctx context.Context, userID string) ([]models.Transaction, error) {
+	query := `SELECT user_id, id, timestamp, token_id, type, amount, price, fee, wallet, to_wallet, note 
+              FROM transactions WHERE user_id = ?`
+
+	iter := db.Session.Query(query, userID).WithContext(ctx).Iter()
+
+	transactions := make([]models.Transaction, 0)
+	var tx models.Transaction
+	var id gocql.UUID
+	var txType string
+
+	for iter.Scan(&tx.UserID, &id, &tx.Timestamp, &tx.TokenID, &txType, &tx.Amount,
+		&tx.Price, &tx.Fee, &tx.Wallet, &tx.ToWallet, &tx.Note) {
+		tx.ID = id.String()
+		tx.Type = models.TransactionType(txType)
+		transactions = append(transactions, tx)
+		tx = models.Transaction{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// DeleteTransaction removes a transaction from a user's ledger
+func (db *ScyllaDB) DeleteTransaction(ctx context.Context, userID, id string) error {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `DELETE FROM transactions WHERE user_id = ? AND id = ?`
+
+	if err := db.Session.Query(query, userID, uuid).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to delete transaction: %w", err)
+	}
+
+	return nil
+}
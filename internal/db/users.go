@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// ErrConflict is returned when a row that must be unique already exists
+var ErrConflict = errors.New("already exists")
+
+// CreateUser inserts a user unless the username is taken. The lightweight
+// transaction keeps concurrent registrations of one name from overwriting each other.
+func (db *ScyllaDB) CreateUser(ctx context.Context, user models.User) error {
+	query := `INSERT INTO users (username, password_hash, role, created_at) 
+              VALUES (?, ?, ?, ?) IF NOT EXISTS`
+
+	applied, err := db.Session.Query(query,
+		user.Username, user.PasswordHash, string(user.Role), user.CreatedAt).WithContext(ctx).
+		MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	if !applied {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// GetUser returns the account of a username
+func (db *ScyllaDB) GetUser(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT username, password_hash, role, created_at FROM users WHERE username = ?`
+
+	var user models.User
+	var role string
+	if err := db.Session.Query(query, username).WithContext(ctx).Scan(
+		&user.Username, &user.PasswordHash, &role, &user.CreatedAt); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user.Role = models.Role(role)
+
+	return &user, nil
+}
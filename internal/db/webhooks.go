@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// webhookQueueShard is the single partition of webhook_queue
+const webhookQueueShard = 0
+
+// SaveWebhook inserts a webhook with a timeuuid derived from its creation time
+func (db *ScyllaDB) SaveWebhook(ctx context.Context, hook *models.Webhook) error {
+	id := gocql.UUIDFromTime(hook.CreatedAt)
+
+	query := `INSERT INTO webhooks (user_id, id, url, events, secret, created_at) 
+              VALUES (?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		hook.UserID, id, hook.URL, hook.Events, hook.Secret, hook.CreatedAt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	hook.ID = id.String()
+	return nil
+}
+
+// GetWebhook returns a single webhook of a user
+func (db *ScyllaDB) GetWebhook(ctx context.Context, userID, id string) (*models.Webhook, error) {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT user_id, id, url, events, secret, created_at FROM webhooks 
+              WHERE user_id = ? AND id = ?`
+
+	hooks, err := db.scanWebhooks(db.Session.Query(query, userID, uuid).WithContext(ctx).Iter())
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &hooks[0], nil
+}
+
+// GetWebhooks returns a user's webhooks in creation order
+func (db *ScyllaDB) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	query := `SELECT user_id, id, url, events, secret, created_at FROM webhooks WHERE user_id = ?`
+
+	return db.scanWebhooks(db.Session.Query(query, userID).WithContext(ctx).Iter())
+}
+
+func (db *ScyllaDB) scanWebhooks(iter *gocql.Iter) ([]models.Webhook, error) {
+	hooks := make([]models.Webhook, 0)
+	var hook models.Webhook
+	var id gocql.UUID
+
+	for iter.Scan(&hook.UserID, &id, &hook.URL, &hook.Events, &hook.Secret, &hook.CreatedAt) {
+		hook.ID = id.String()
+		hooks = append(hooks, hook)
+		hook = models.Webhook{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook; its delivery log is kept
+func (db *ScyllaDB) DeleteWebhook(ctx context.Context, userID, id string) error {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `DELETE FROM webhooks WHERE user_id = ? AND id = ?`
+
+	if err := db.Session.Query(query, userID, uuid).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// SaveDelivery inserts or replaces a delivery in the log and keeps the queue
+// of pending deliveries in step with its status
+func (db *ScyllaDB) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	id := gocql.UUIDFromTime(delivery.CreatedAt)
+	if delivery.ID != "" {
+		parsed, err := gocql.ParseUUID(delivery.ID)
+		if err != nil {
+			return ErrInvalidID
+		}
+		id = parsed
+	}
+	webhookID, err := gocql.ParseUUID(delivery.WebhookID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	query := `INSERT INTO webhook_deliveries (user_id, id, webhook_id, event, url, payload, status, attempts, response_code, error, next_attempt_at, created_at, updated_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if err := db.Session.Query(query,
+		delivery.UserID, id, webhookID, delivery.Event, delivery.URL, string(delivery.Payload),
+		string(delivery.Status), delivery.Attempts, delivery.ResponseCode, delivery.Error,
+		delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	if delivery.Status == models.DeliveryPending {
+		query = `INSERT INTO webhook_queue (shard, id, user_id, next_attempt_at) VALUES (?, ?, ?, ?)`
+		err = db.Session.Query(query, webhookQueueShard, id, delivery.UserID, delivery.NextAttemptAt).WithContext(ctx).Exec()
+	} else {
+		query = `DELETE FROM webhook_queue WHERE shard = ? AND id = ?`
+		err = db.Session.Query(query, webhookQueueShard, id).WithContext(ctx).Exec()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update webhook queue: %w", err)
+	}
+
+	delivery.ID = id.String()
+	return nil
+}
+
+// GetDelivery returns a single delivery of a user
+func (db *ScyllaDB) GetDelivery(ctx context.Context, userID, id string) (*models.WebhookDelivery, error) {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT user_id, id, webhook_id, event, url, payload, status, attempts, response_code, error, next_attempt_at, created_at, updated_at 
+              FROM webhook_deliveries WHERE user_id = ? AND id = ?`
+
+	deliveries, err := db.scanDeliveries(db.Session.Query(query, userID, uuid).WithContext(ctx).Iter())
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &deliveries[0], nil
+}
+
+// GetDeliveries returns up to limit of a user's deliveries, newest first
+func (db *ScyllaDB) GetDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT user_id, id, webhook_id, event, url, payload, status, attempts, response_code, error, next_attempt_at, created_at, updated_at 
+              FROM webhook_deliveries WHERE user_id = ? LIMIT ?`
+
+	return db.scanDeliveries(db.Session.Query(query, userID, limit).WithContext(ctx).Iter())
+}
+
+// PendingDeliveries reads the queue and returns the due deliveries, oldest first
+func (db *ScyllaDB) PendingDeliveries(ctx context.Context, t time.Time, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT id, user_id, next_attempt_at FROM webhook_queue WHERE shard = ?`
+
+	iter := db.Session.Query(query, webhookQueueShard).WithContext(ctx).Iter()
+
+	type queued struct {
+		id, userID    string
+		nextAttemptAt time.Time
+	}
+	due := make([]queued, 0)
+	var id gocql.UUID
+	var entry queued
+
+	for iter.Scan(&id, &entry.userID, &entry.nextAttemptAt) {
+		if !entry.nextAttemptAt.After(t) {
+			entry.id = id.String()
+			due = append(due, entry)
+		}
+		entry = queued{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook queue: %w", err)
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].nextAttemptAt.Before(due[j].nextAttemptAt) })
+
+	deliveries := make([]models.WebhookDelivery, 0, min(limit, len(due)))
+	for _, entry := range due {
+		if len(deliveries) == limit {
+			break
+		}
+
+		delivery, err := db.GetDelivery(ctx, entry.userID, entry.id)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (db *ScyllaDB) scanDeliveries(iter *gocql.Iter) ([]models.WebhookDelivery, error) {
+	deliveries := make([]models.WebhookDelivery, 0)
+	var delivery models.WebhookDelivery
+	var id, webhookID gocql.UUID
+	var payload, status string
+	var nextAttemptAt time.Time
+
+	for iter.Scan(&delivery.UserID, &id, &webhookID, &delivery.Event, &delivery.URL, &payload, &status,
+		&delivery.Attempts, &delivery.ResponseCode, &delivery.Error, &nextAttemptAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt) {
+		delivery.ID = id.String()
+		delivery.WebhookID = webhookID.String()
+		delivery.Payload = []byte(payload)
+		delivery.Status = models.DeliveryStatus(status)
+		delivery.NextAttemptAt = optionalTime(nextAttemptAt)
+		deliveries = append(deliveries, delivery)
+		delivery = models.WebhookDelivery{} // Reset for next iteration
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxAlertEvents bounds a page of the triggered alert history
+const maxAlertEvents = 1000
+
+// List a user's alerts
+func (h *Handler) GetAlerts(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	alerts, err := h.Stores.Alerts.GetAlerts(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch alerts")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id": userID,
+		"alerts":  alerts,
+		"count":   len(alerts),
+	})
+}
+
+// Get a single alert of a user
+func (h *Handler) GetAlert(c *fiber.Ctx) error {
+	alert, err := h.Stores.Alerts.GetAlert(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Alert not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch alert")
+	}
+
+	return c.JSON(alert)
+}
+
+// Create a price, percent change or portfolio value alert
+func (h *Handler) CreateAlert(c *fiber.Ctx) error {
+	var alert models.Alert
+	if err := c.BodyParser(&alert); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	alert.ID = ""
+	alert.UserID = c.Params("user")
+	alert.CreatedAt = time.Now()
+	armAlert(&alert)
+
+	if err := services.ValidateAlert(&alert); err != nil {
+		return err
+	}
+
+	if err := h.Stores.Alerts.SaveAlert(c.Context(), &alert); err != nil {
+		return apierror.Internal("Failed to save alert")
+	}
+
+	return c.Status(201).JSON(alert)
+}
+
+// Replace the rule of an existing alert, which re-arms it
+func (h *Handler) UpdateAlert(c *fiber.Ctx) error {
+	existing, err := h.Stores.Alerts.GetAlert(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Alert not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch alert")
+	}
+
+	var alert models.Alert
+	if err := c.BodyParser(&alert); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	alert.ID = existing.ID
+	alert.UserID = existing.UserID
+	alert.CreatedAt = existing.CreatedAt
+	armAlert(&alert)
+
+	if err := services.ValidateAlert(&alert); err != nil {
+		return err
+	}
+
+	if err := h.Stores.Alerts.SaveAlert(c.Context(), &alert); err != nil {
+		return apierror.Internal("Failed to save alert")
+	}
+
+	return c.JSON(alert)
+}
+
+// Delete an alert of a user
+func (h *Handler) DeleteAlert(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	id := c.Params("id")
+
+	_, err := h.Stores.Alerts.GetAlert(c.Context(), userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Alert not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch alert")
+	}
+
+	if err := h.Stores.Alerts.DeleteAlert(c.Context(), userID, id); err != nil {
+		return apierror.Internal("Failed to delete alert")
+	}
+
+	return c.SendStatus(204)
+}
+
+// Get a user's triggered alerts, newest first
+func (h *Handler) GetAlertHistory(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	limit, err := queryInt(c, "limit", 100, 1, maxAlertEvents)
+	if err != nil {
+		return err
+	}
+
+	events, err := h.Stores.Alerts.GetAlertEvents(c.Context(), userID, limit)
+	if err != nil {
+		return apierror.Internal("Failed to fetch alert history")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id": userID,
+		"events":  events,
+		"count":   len(events),
+	})
+}
+
+// armAlert resets the evaluation state of a new or edited alert
+func armAlert(alert *models.Alert) {
+	alert.State = models.AlertArmed
+	alert.TriggeredAt = nil
+	alert.CooldownUntil = nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// List a user's API keys, revoked ones included
+func (h *Handler) GetAPIKeys(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	keys, err := h.Stores.APIKeys.GetAPIKeys(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch API keys")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":  userID,
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// Get a single API key of a user
+func (h *Handler) GetAPIKey(c *fiber.Ctx) error {
+	key, err := h.Stores.APIKeys.GetAPIKey(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("API key not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch API key")
+	}
+
+	return c.JSON(key)
+}
+
+// Issue an API key; the response holds the key, which is not shown again
+func (h *Handler) CreateAPIKey(c *fiber.Ctx) error {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	key, raw, err := h.Auth.CreateAPIKey(c.Context(), c.Params("user"), req.Name, req.Scopes)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(201).JSON(struct {
+		*models.APIKey
+		Key string `json:"key"`
+	}{key, raw})
+}
+
+// Revoke an API key; it stays listed with its revocation time
+func (h *Handler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	id := c.Params("id")
+
+	key, err := h.Stores.APIKeys.GetAPIKey(c.Context(), userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("API key not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch API key")
+	}
+
+	if key.RevokedAt == nil {
+		if err := h.Stores.APIKeys.RevokeAPIKey(c.Context(), userID, id, time.Now()); err != nil {
+			return apierror.Internal("Failed to revoke API key")
+		}
+	}
+
+	return c.SendStatus(204)
+}
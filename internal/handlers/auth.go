@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// principalKey holds the authenticated caller in the request locals
+const principalKey = "principal"
+
+// HeaderAPIKey carries API keys
+const HeaderAPIKey = "X-API-Key"
+
+// credentials is the body of register and login requests
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Register a user account
+func (h *Handler) Register(c *fiber.Ctx) error {
+	var req credentials
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	user, err := h.Auth.Register(c.Context(), req.Username, req.Password)
+	if errors.Is(err, db.ErrConflict) {
+		return apierror.Conflict("Username is already taken")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(201).JSON(user)
+}
+
+// Log in with a username and password, returning an access and a refresh token
+func (h *Handler) Login(c *fiber.Ctx) error {
+	var req credentials
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	tokens, err := h.Auth.Login(c.Context(), req.Username, req.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		return apierror.Unauthorized("Invalid username or password")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to log in")
+	}
+
+	return c.JSON(tokens)
+}
+
+// Exchange a refresh token for a new token pair
+func (h *Handler) RefreshToken(c *fiber.Ctx) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if req.RefreshToken == "" {
+		return apierror.Field("refresh_token", "is required")
+	}
+
+	tokens, err := h.Auth.Refresh(c.Context(), req.RefreshToken)
+	if errors.Is(err, services.ErrInvalidToken) {
+		return apierror.Unauthorized("Invalid or expired refresh token")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to refresh token")
+	}
+
+	return c.JSON(tokens)
+}
+
+// Get the authenticated user
+func (h *Handler) Me(c *fiber.Ctx) error {
+	principal := currentPrincipal(c)
+
+	user, err := h.Stores.Users.GetUser(c.Context(), principal.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("User not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+
+	return c.JSON(user)
+}
+
+// Authenticate identifies the caller from an X-API-Key header or a bearer
+// access token. Requests without either continue anonymously; the Require*
+// middleware decides what they may reach.
+func (h *Handler) Authenticate(c *fiber.Ctx) error {
+	var principal *services.Principal
+	var err error
+	if key := c.Get(HeaderAPIKey); key != "" {
+		principal, err = h.Auth.AuthenticateAPIKey(c.Context(), key)
+	} else if token := bearerToken(c); token != "" {
+		principal, err = h.Auth.Authenticate(token)
+	} else {
+		return c.Next()
+	}
+
+	if errors.Is(err, services.ErrInvalidToken) {
+		return unauthorized(c)
+	}
+	if err != nil {
+		return apierror.Internal("Failed to authenticate request")
+	}
+
+	c.Locals(principalKey, principal)
+	return c.Next()
+}
+
+// RequireAuth rejects anonymous requests; it runs after Authenticate
+func (h *Handler) RequireAuth(c *fiber.Ctx) error {
+	if currentPrincipal(c).UserID == "" {
+		return unauthorized(c)
+	}
+	return c.Next()
+}
+
+// RequireAdmin rejects callers that are not admins, and admin API keys
+// without the admin:sync scope; it runs after RequireAuth
+func (h *Handler) RequireAdmin(c *fiber.Ctx) error {
+	principal := currentPrincipal(c)
+	if !principal.IsAdmin() {
+		return apierror.Forbidden("Admin role required")
+	}
+	if !principal.HasScope(models.ScopeAdminSync) {
+		return scopeError(models.ScopeAdminSync)
+	}
+	return c.Next()
+}
+
+// RequireSelf restricts routes of a :user to that user and to admins; it runs after RequireAuth
+func (h *Handler) RequireSelf(c *fiber.Ctx) error {
+	if !canAccessUser(currentPrincipal(c), c.Params("user")) {
+		return apierror.Forbidden("Access to this user's data is not allowed")
+	}
+	return c.Next()
+}
+
+// RequireScope rejects API keys without a scope. Anonymous callers pass, so
+// it also guards public routes.
+func (h *Handler) RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !currentPrincipal(c).HasScope(scope) {
+			return scopeError(scope)
+		}
+		return c.Next()
+	}
+}
+
+// RequirePortfolioScope asks API keys for read:portfolio on reads and
+// write:portfolio on changes of per-user data
+func (h *Handler) RequirePortfolioScope(c *fiber.Ctx) error {
+	scope := models.ScopeWritePortfolio
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		scope = models.ScopeReadPortfolio
+	}
+	return h.RequireScope(scope)(c)
+}
+
+// RequireSession rejects API keys, so a leaked key cannot issue more keys
+func (h *Handler) RequireSession(c *fiber.Ctx) error {
+	if currentPrincipal(c).APIKeyID != "" {
+		return apierror.Forbidden("API keys cannot be managed with an API key")
+	}
+	return c.Next()
+}
+
+func currentPrincipal(c *fiber.Ctx) *services.Principal {
+	principal, _ := c.Locals(principalKey).(*services.Principal)
+	if principal == nil {
+		return &services.Principal{}
+	}
+	return principal
+}
+
+func canAccessUser(principal *services.Principal, userID string) bool {
+	return principal.UserID == userID || principal.IsAdmin()
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(c *fiber.Ctx) string {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer`)
+	return apierror.Unauthorized("Missing or invalid access token or API key")
+}
+
+func scopeError(scope string) error {
+	return apierror.New(403, apierror.CodeInsufficientScope, fmt.Sprintf("API key lacks the '%s' scope", scope))
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Start backfilling the price history of a token from the price provider
+func (h *Handler) BackfillPriceHistory(c *fiber.Ctx) error {
+	tokenID := c.Params("id")
+
+	days, err := queryInt(c, "days", 30, 1, h.Backfiller.MaxDays)
+	if err != nil {
+		return err
+	}
+
+	// The backfill outlives the request, so it must not use the request context
+	state, err := h.Backfiller.Start(context.Background(), tokenID, days)
+	if errors.Is(err, services.ErrBackfillRunning) {
+		return apierror.Conflict(err.Error())
+	}
+	if err != nil {
+		return apierror.Internal("Failed to start backfill")
+	}
+
+	return c.Status(202).JSON(state)
+}
+
+// Get the progress of a token's backfill
+func (h *Handler) GetBackfill(c *fiber.Ctx) error {
+	tokenID := c.Params("id")
+
+	state, err := h.Stores.Backfills.GetBackfill(c.Context(), tokenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("No backfill found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch backfill")
+	}
+
+	return c.JSON(state)
+}
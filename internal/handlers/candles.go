@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCandles caps the number of candles of a single request
+const maxCandles = 5000
+
+// Get OHLC candles of a token, defaulting to the last 100 intervals
+func (h *Handler) GetCandles(c *fiber.Ctx) error {
+	tokenID := c.Params("id")
+	interval := c.Query("interval", "1h")
+
+	d, err := services.ParseInterval(interval)
+	if err != nil {
+		return apierror.Field("interval", "%s", err.Error())
+	}
+
+	from, to, err := parseTimeRange(c.Query("from"), c.Query("to"), 100*d)
+	if err != nil {
+		return err
+	}
+	if to.Sub(from)/d >= maxCandles {
+		return apierror.Field("from", "range and interval produce more than %d candles", maxCandles)
+	}
+
+	candles, err := h.Stores.Candles.GetCandles(c.Context(), tokenID, interval, from.Truncate(d), to)
+	if err != nil {
+		return apierror.Internal("Failed to fetch candles")
+	}
+
+	// History recorded before rollups existed is only available as raw points
+	if len(candles) == 0 {
+		points, err := h.Stores.Prices.GetPriceRange(c.Context(), tokenID, from.Truncate(d), to)
+		if err != nil {
+			return apierror.Internal("Failed to fetch price history")
+		}
+		candles = services.BuildCandles(points, d)
+	}
+
+	return c.JSON(fiber.Map{
+		"token_id": tokenID,
+		"interval": interval,
+		"from":     from,
+		"to":       to,
+		"count":    len(candles),
+		"candles":  candles,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// converter builds the currency converter for the ?currency= query parameter
+func (h *Handler) converter(c *fiber.Ctx) (services.Converter, error) {
+	currency := c.Query("currency", services.BaseCurrency)
+	if currency == services.BaseCurrency {
+		return services.NewConverter(currency, nil)
+	}
+
+	rates, err := h.Stores.FX.GetRates(c.Context())
+	if err != nil {
+		return services.Converter{}, apierror.Internal("Failed to fetch exchange rates")
+	}
+
+	conv, err := services.NewConverter(currency, rates)
+	if errors.Is(err, services.ErrUnsupportedCurrency) {
+		return services.Converter{}, apierror.Field("currency", "%s", err.Error())
+	}
+	return conv, err
+}
+
+// Get the stored exchange rates
+func (h *Handler) GetExchangeRates(c *fiber.Ctx) error {
+	rates, err := h.Stores.FX.GetRates(c.Context())
+	if err != nil {
+		return apierror.Internal("Failed to fetch exchange rates")
+	}
+
+	return c.JSON(fiber.Map{
+		"base":  services.BaseCurrency,
+		"rates": rates,
+		"count": len(rates),
+	})
+}
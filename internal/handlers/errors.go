@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler writes the errors returned by handlers and middleware. API
+// errors keep their status and code; Fiber's own errors, such as unknown
+// routes, get the code of their status; anything else is an internal error
+// whose cause is logged rather than shown to the client.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var apiErr *apierror.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &fiberErr):
+		apiErr = apierror.New(fiberErr.Code, statusCode(fiberErr.Code), fiberErr.Message)
+	default:
+		log.Printf("❌ %s %s failed: %v", c.Method(), c.Path(), err)
+		apiErr = apierror.Internal("Internal server error")
+	}
+
+	return c.Status(apiErr.Status).JSON(apiErr)
+}
+
+// statusCode picks the error code of a bare HTTP status
+func statusCode(status int) apierror.Code {
+	switch status {
+	case 401:
+		return apierror.CodeUnauthorized
+	case 403:
+		return apierror.CodeForbidden
+	case 404:
+		return apierror.CodeNotFound
+	case 405:
+		return apierror.CodeMethodNotAllowed
+	case 409:
+		return apierror.CodeConflict
+	case 422:
+		return apierror.CodeUnprocessable
+	case 429:
+		return apierror.CodeRateLimited
+	case 502:
+		return apierror.CodeUpstream
+	}
+	if status >= 500 {
+		return apierror.CodeInternal
+	}
+	return apierror.CodeInvalidRequest
+}
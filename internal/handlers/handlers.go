@@ -1,27 +1,43 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/config"
 	"crypto-portfolio-tracker/internal/db"
 	"crypto-portfolio-tracker/internal/models"
 	"crypto-portfolio-tracker/internal/services"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type Handler struct {
-	ScyllaDB      *db.ScyllaDB
-	ElasticSearch *db.ElasticSearch
+	Stores     db.Stores
+	Provider   services.PriceProvider
+	Backfiller *services.Backfiller
+	Webhooks   *services.WebhookDispatcher
+	Stream     *services.StreamHub
+	Auth       *services.Authenticator
+	Limiter    *services.QuotaLimiter
 }
 
-func NewHandler(scylla *db.ScyllaDB, es *db.ElasticSearch) *Handler {
+func NewHandler(stores db.Stores, provider services.PriceProvider, backfiller *services.Backfiller,
+	webhooks *services.WebhookDispatcher, stream *services.StreamHub, auth *services.Authenticator,
+	limiter *services.QuotaLimiter) *Handler {
 	return &Handler{
-		ScyllaDB:      scylla,
-		ElasticSearch: es,
+		Stores:     stores,
+		Provider:   provider,
+		Backfiller: backfiller,
+		Webhooks:   webhooks,
+		Stream:     stream,
+		Auth:       auth,
+		Limiter:    limiter,
 	}
 }
 
@@ -33,53 +49,47 @@ func (h *Handler) HealthCheck(c *fiber.Ctx) error {
 	})
 }
 
-// Add a token to both ScyllaDB and ElasticSearch
+// Add a token to both the token store and the search index
 func (h *Handler) AddToken(c *fiber.Ctx) error {
 	var token models.Token
 	if err := c.BodyParser(&token); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return apierror.BadRequest("Invalid request body")
 	}
 
-	token.UpdatedAt = time.Now()
-
-	// Insert into ScyllaDB
-	query := `INSERT INTO tokens (id, symbol, name, current_price, market_cap, volume_24h, updated_at) 
-              VALUES (?, ?, ?, ?, ?, ?, ?)`
-
-	if err := h.ScyllaDB.Session.Query(query,
-		token.ID, token.Symbol, token.Name, token.CurrentPrice,
-		token.MarketCap, token.Volume24h, token.UpdatedAt).Exec(); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert into ScyllaDB"})
+	var v apierror.Validator
+	v.Check(strings.TrimSpace(token.ID) != "", "id", "is required")
+	v.Check(strings.TrimSpace(token.Symbol) != "", "symbol", "is required")
+	v.Check(strings.TrimSpace(token.Name) != "", "name", "is required")
+	v.Check(token.CurrentPrice >= 0, "current_price", "must be non-negative")
+	v.Check(token.MarketCap >= 0, "market_cap", "must be non-negative")
+	v.Check(token.Volume24h >= 0, "volume_24h", "must be non-negative")
+	if err := v.Err(); err != nil {
+		return err
 	}
 
-	// Index in ElasticSearch
-	tokenMap := map[string]interface{}{
-		"id":            token.ID,
-		"symbol":        token.Symbol,
-		"name":          token.Name,
-		"current_price": token.CurrentPrice,
-		"market_cap":    token.MarketCap,
-		"volume_24h":    token.Volume24h,
-		"updated_at":    token.UpdatedAt,
+	token.UpdatedAt = time.Now()
+
+	if err := h.Stores.Tokens.SaveToken(c.Context(), token); err != nil {
+		return apierror.Internal("Failed to save token")
 	}
 
-	if err := h.ElasticSearch.IndexToken(context.Background(), tokenMap); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to index in ElasticSearch"})
+	if err := h.Stores.Search.IndexToken(context.Background(), token); err != nil {
+		return apierror.Internal("Failed to index token")
 	}
 
 	return c.Status(201).JSON(token)
 }
 
-// Search tokens using ElasticSearch
+// Search tokens using the search index
 func (h *Handler) SearchTokens(c *fiber.Ctx) error {
 	query := c.Query("q", "")
 	if query == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Query parameter 'q' is required"})
+		return apierror.Field("q", "is required")
 	}
 
-	tokens, err := h.ElasticSearch.SearchTokens(context.Background(), query)
+	tokens, err := h.Stores.Search.SearchTokens(context.Background(), query)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Search failed"})
+		return apierror.Internal("Search failed")
 	}
 
 	return c.JSON(fiber.Map{
@@ -89,63 +99,64 @@ func (h *Handler) SearchTokens(c *fiber.Ctx) error {
 	})
 }
 
-// Get token by ID from ScyllaDB
+// Get token by ID
 func (h *Handler) GetToken(c *fiber.Ctx) error {
 	tokenID := c.Params("id")
 
-	var token models.Token
-	query := `SELECT id, symbol, name, current_price, market_cap, volume_24h, updated_at 
-              FROM tokens WHERE id = ? LIMIT 1`
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
 
-	if err := h.ScyllaDB.Session.Query(query, tokenID).Scan(
-		&token.ID, &token.Symbol, &token.Name, &token.CurrentPrice,
-		&token.MarketCap, &token.Volume24h, &token.UpdatedAt); err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Token not found"})
+	token, err := h.Stores.Tokens.GetToken(c.Context(), tokenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Token not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch token")
 	}
 
-	return c.JSON(token)
+	return c.JSON(conv.Token(*token))
 }
 
-// Sync tokens from CoinGecko
+// Sync tokens from the configured price provider
 func (h *Handler) SyncTokens(c *fiber.Ctx) error {
-	limitStr := c.Query("limit", "10")
-	limit := 10
-	fmt.Sscanf(limitStr, "%d", &limit)
-
-	// Create CoinGecko client
-	cgClient := services.NewCoinGeckoClient()
+	limit, err := queryInt(c, "limit", 10, 1, config.MaxTopTokens)
+	if err != nil {
+		return err
+	}
 
-	// Fetch tokens
-	tokens, err := cgClient.FetchTopTokens(limit)
+	// Fetch an explicit list of IDs when given, otherwise the top tokens
+	var tokens []models.Token
+	if ids := c.Query("ids"); ids != "" {
+		idList := strings.Split(ids, ",")
+		if len(idList) > config.MaxTopTokens {
+			return apierror.Field("ids", "must list at most %d token IDs", config.MaxTopTokens)
+		}
+		tokens, err = h.Provider.FetchTokens(c.Context(), idList)
+	} else {
+		tokens, err = h.Provider.FetchTopTokens(c.Context(), limit)
+	}
+	var apiErr *services.APIError
+	if errors.As(err, &apiErr) && errors.Is(err, services.ErrRateLimited) {
+		if apiErr.RetryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+		}
+		return apierror.RateLimited("Price provider rate limit reached, try again later")
+	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		log.Printf("❌ Failed to fetch tokens from price provider: %v", err)
+		return apierror.Upstream("Price provider unavailable")
 	}
 
-	// Save to ScyllaDB and ElasticSearch
+	// Save to the token store and search index
 	successCount := 0
 	for _, token := range tokens {
-		// Insert into ScyllaDB
-		query := `INSERT INTO tokens (id, symbol, name, current_price, market_cap, volume_24h, updated_at) 
-                  VALUES (?, ?, ?, ?, ?, ?, ?)`
-
-		if err := h.ScyllaDB.Session.Query(query,
-			token.ID, token.Symbol, token.Name, token.CurrentPrice,
-			token.MarketCap, token.Volume24h, token.UpdatedAt).Exec(); err != nil {
+		if err := h.Stores.Tokens.SaveToken(context.Background(), token); err != nil {
 			continue // Skip failed inserts
 		}
 
-		// Index in ElasticSearch
-		tokenMap := map[string]interface{}{
-			"id":            token.ID,
-			"symbol":        token.Symbol,
-			"name":          token.Name,
-			"current_price": token.CurrentPrice,
-			"market_cap":    token.MarketCap,
-			"volume_24h":    token.Volume24h,
-			"updated_at":    token.UpdatedAt,
-		}
-
-		if err := h.ElasticSearch.IndexToken(context.Background(), tokenMap); err != nil {
+		if err := h.Stores.Search.IndexToken(context.Background(), token); err != nil {
 			continue
 		}
 
@@ -159,131 +170,91 @@ func (h *Handler) SyncTokens(c *fiber.Ctx) error {
 	})
 }
 
-// Get price history for a token
+// maxHistoryLimit caps the page size of a price history request
+const maxHistoryLimit = 1000
+
+// Get a page of price history for a token, newest first
 func (h *Handler) GetPriceHistory(c *fiber.Ctx) error {
 	tokenID := c.Params("id")
-	limitStr := c.Query("limit", "100")
-	limit := 100
-	fmt.Sscanf(limitStr, "%d", &limit)
-
-	query := `SELECT token_id, timestamp, price FROM price_history 
-              WHERE token_id = ? LIMIT ?`
 
-	iter := h.ScyllaDB.Session.Query(query, tokenID, limit).Iter()
+	limit, err := queryInt(c, "limit", 100, 1, maxHistoryLimit)
+	if err != nil {
+		return err
+	}
 
-	type PricePoint struct {
-		TokenID   string    `json:"token_id"`
-		Timestamp time.Time `json:"timestamp"`
-		Price     float64   `json:"price"`
+	query := db.PriceQuery{Limit: limit, Cursor: c.Query("cursor")}
+	if from := c.Query("from"); from != "" {
+		if query.From, err = parseTime("from", from); err != nil {
+			return err
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = parseTime("to", to); err != nil {
+			return err
+		}
 	}
 
-	history := make([]PricePoint, 0)
-	var point PricePoint
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
 
-	for iter.Scan(&point.TokenID, &point.Timestamp, &point.Price) {
-		history = append(history, point)
-		point = PricePoint{} // Reset for next iteration
+	history, next, err := h.Stores.Prices.GetPriceHistory(c.Context(), tokenID, query)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		return apierror.Field("cursor", "is not a cursor returned by this endpoint")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch price history")
 	}
 
-	if err := iter.Close(); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch price history"})
+	if len(history) == 0 && query.Cursor == "" {
+		return apierror.NotFound("No price history found")
 	}
 
-	if len(history) == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "No price history found"})
+	for i, point := range history {
+		history[i] = conv.PricePoint(point)
 	}
 
 	return c.JSON(fiber.Map{
-		"token_id": tokenID,
-		"count":    len(history),
-		"history":  history,
+		"token_id":    tokenID,
+		"currency":    conv.Currency,
+		"count":       len(history),
+		"history":     history,
+		"next_cursor": next,
 	})
 }
 
-// Get analytics from ElasticSearch
+// Get analytics from the search index
 func (h *Handler) GetAnalytics(c *fiber.Ctx) error {
-	var buf bytes.Buffer
-
-	// Aggregation query
-	aggQuery := map[string]interface{}{
-		"size": 0,
-		"aggs": map[string]interface{}{
-			"avg_price": map[string]interface{}{
-				"avg": map[string]interface{}{
-					"field": "current_price",
-				},
-			},
-			"total_market_cap": map[string]interface{}{
-				"sum": map[string]interface{}{
-					"field": "market_cap",
-				},
-			},
-			"top_tokens": map[string]interface{}{
-				"terms": map[string]interface{}{
-					"field": "symbol",
-					"size":  10,
-					"order": map[string]interface{}{
-						"by_market_cap": "desc",
-					},
-				},
-				"aggs": map[string]interface{}{
-					"by_market_cap": map[string]interface{}{
-						"max": map[string]interface{}{
-							"field": "market_cap",
-						},
-					},
-				},
-			},
-		},
-	}
-
-	if err := json.NewEncoder(&buf).Encode(aggQuery); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to encode query"})
-	}
-
-	res, err := h.ElasticSearch.Client.Search(
-		h.ElasticSearch.Client.Search.WithContext(context.Background()),
-		h.ElasticSearch.Client.Search.WithIndex("crypto_tokens"),
-		h.ElasticSearch.Client.Search.WithBody(&buf),
-	)
+	aggs, err := h.Stores.Search.Analytics(context.Background())
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Search failed"})
-	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode response"})
+		return apierror.Internal("Search failed")
 	}
 
-	aggs := result["aggregations"].(map[string]interface{})
-
 	return c.JSON(fiber.Map{
 		"analytics": aggs,
 	})
 }
 
-// Get all tokens from ScyllaDB
+// Get all tokens from the token store
 func (h *Handler) GetAllTokens(c *fiber.Ctx) error {
-	query := `SELECT id, symbol, name, current_price, market_cap, volume_24h, updated_at FROM tokens`
-
-	iter := h.ScyllaDB.Session.Query(query).Iter()
-
-	tokens := make([]models.Token, 0)
-	var token models.Token
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
 
-	for iter.Scan(&token.ID, &token.Symbol, &token.Name, &token.CurrentPrice,
-		&token.MarketCap, &token.Volume24h, &token.UpdatedAt) {
-		tokens = append(tokens, token)
-		token = models.Token{} // Reset for next iteration
+	tokens, err := h.Stores.Tokens.ListTokens(c.Context())
+	if err != nil {
+		return apierror.Internal("Failed to fetch tokens")
 	}
 
-	if err := iter.Close(); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch tokens"})
+	for i, token := range tokens {
+		tokens[i] = conv.Token(token)
 	}
 
 	return c.JSON(fiber.Map{
-		"tokens": tokens,
-		"count":  len(tokens),
+		"tokens":   tokens,
+		"currency": conv.Currency,
+		"count":    len(tokens),
 	})
 }
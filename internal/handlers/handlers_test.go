@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testAPI is an app wired like cmd/api on the memory store, without a price
+// provider, background workers or rate limits
+type testAPI struct {
+	app    *fiber.App
+	stores db.Stores
+	auth   *services.Authenticator
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	stores := db.NewMemoryStores()
+	auth, err := services.NewAuthenticator(stores, config.AuthConfig{
+		JWTSecret:  "test-secret",
+		AccessTTL:  time.Hour,
+		RefreshTTL: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	h := NewHandler(stores, nil, nil, nil, nil, auth, nil)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	api := app.Group("/api/v1", h.Authenticate)
+	api.Post("/auth/register", h.Register)
+	api.Post("/auth/login", h.Login)
+	api.Get("/auth/me", h.RequireAuth, h.Me)
+	api.Get("/history/:id", h.RequireScope(models.ScopeReadMarket), h.GetPriceHistory)
+
+	portfolios := api.Group("/portfolios/:user", h.RequireAuth, h.RequireSelf, h.RequirePortfolioScope)
+	portfolios.Get("/holdings", h.GetHoldings)
+	portfolios.Post("/holdings", h.AddHolding)
+	portfolios.Get("/holdings/:token", h.GetHolding)
+	portfolios.Put("/holdings/:token", h.UpdateHolding)
+	portfolios.Delete("/holdings/:token", h.DeleteHolding)
+
+	return &testAPI{app: app, stores: stores, auth: auth}
+}
+
+// login creates a user with a role and returns its access token
+func (a *testAPI) login(t *testing.T, username string, role models.Role) string {
+	if _, err := a.auth.CreateUser(context.Background(), username, "password123", role); err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	tokens, err := a.auth.Login(context.Background(), username, "password123")
+	if err != nil {
+		t.Fatalf("failed to log in %s: %v", username, err)
+	}
+	return tokens.AccessToken
+}
+
+// do sends a request with an optional JSON body and bearer token, decodes
+// the JSON response into out when given, and returns the status
+func (a *testAPI) do(t *testing.T, method, path, token string, body, out interface{}) int {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// apiError is the body of an error response
+type apiError struct {
+	Error string        `json:"error"`
+	Code  apierror.Code `json:"code"`
+}
+
+func TestAuth(t *testing.T) {
+	api := newTestAPI(t)
+
+	creds := credentials{Username: "alice", Password: "password123"}
+	var user models.User
+	if status := api.do(t, "POST", "/api/v1/auth/register", "", creds, &user); status != 201 || user.Username != "alice" || user.Role != models.RoleUser {
+		t.Fatalf("expected alice to register as a user, got %d %+v", status, user)
+	}
+
+	var errBody apiError
+	if status := api.do(t, "POST", "/api/v1/auth/register", "", creds, &errBody); status != 409 || errBody.Code != apierror.CodeConflict {
+		t.Errorf("expected a taken username to conflict, got %d %+v", status, errBody)
+	}
+	if status := api.do(t, "POST", "/api/v1/auth/register", "", credentials{Username: "bo", Password: "short"}, &errBody); status != 400 || errBody.Code != apierror.CodeValidationFailed {
+		t.Errorf("expected invalid credentials to fail validation, got %d %+v", status, errBody)
+	}
+
+	for _, bad := range []credentials{{"alice", "wrong-password"}, {"nobody", "password123"}} {
+		var errBody apiError
+		if status := api.do(t, "POST", "/api/v1/auth/login", "", bad, &errBody); status != 401 || errBody.Code != apierror.CodeUnauthorized {
+			t.Errorf("expected login as %s to be refused, got %d %+v", bad.Username, status, errBody)
+		}
+	}
+
+	var tokens services.TokenPair
+	if status := api.do(t, "POST", "/api/v1/auth/login", "", creds, &tokens); status != 200 || tokens.AccessToken == "" {
+		t.Fatalf("expected alice to log in, got %d %+v", status, tokens)
+	}
+
+	var me models.User
+	if status := api.do(t, "GET", "/api/v1/auth/me", tokens.AccessToken, nil, &me); status != 200 || me.Username != "alice" {
+		t.Errorf("expected the access token to identify alice, got %d %+v", status, me)
+	}
+	if status := api.do(t, "GET", "/api/v1/auth/me", "", nil, &errBody); status != 401 || errBody.Code != apierror.CodeUnauthorized {
+		t.Errorf("expected an anonymous request to be refused, got %d %+v", status, errBody)
+	}
+	if status := api.do(t, "GET", "/api/v1/auth/me", tokens.RefreshToken, nil, &errBody); status != 401 {
+		t.Errorf("expected a refresh token to be refused as an access token, got %d %+v", status, errBody)
+	}
+}
+
+func TestPortfolioAccess(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice", models.RoleUser)
+	bob := api.login(t, "bob", models.RoleUser)
+	admin := api.login(t, "admin", models.RoleAdmin)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"anonymous callers are refused", "", 401},
+		{"a malformed token is refused", "not-a-token", 401},
+		{"other users are forbidden", bob, 403},
+		{"the user may read their own portfolio", alice, 200},
+		{"admins may read any portfolio", admin, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(t, "GET", "/api/v1/portfolios/alice/holdings", tt.token, nil, nil); status != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestHoldings(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.login(t, "alice", models.RoleUser)
+	base := "/api/v1/portfolios/alice/holdings"
+
+	var created models.Portfolio
+	status := api.do(t, "POST", base, token, models.Portfolio{TokenID: "bitcoin", Amount: 0.5, BuyPrice: 40000}, &created)
+	if status != 201 || created.UserID != "alice" || created.BuyDate.IsZero() {
+		t.Fatalf("expected the holding to be created for alice, got %d %+v", status, created)
+	}
+
+	var errBody apiError
+	if status := api.do(t, "POST", base, token, models.Portfolio{TokenID: "bitcoin", Amount: 1}, &errBody); status != 409 {
+		t.Errorf("expected a second bitcoin holding to conflict, got %d %+v", status, errBody)
+	}
+	if status := api.do(t, "POST", base, token, models.Portfolio{TokenID: "ethereum", Amount: -1}, &errBody); status != 400 || errBody.Code != apierror.CodeValidationFailed {
+		t.Errorf("expected a negative amount to fail validation, got %d %+v", status, errBody)
+	}
+
+	// Fields left out of an update keep their stored values
+	var updated models.Portfolio
+	if status := api.do(t, "PUT", base+"/bitcoin", token, map[string]float64{"amount": 0.75}, &updated); status != 200 ||
+		updated.Amount != 0.75 || updated.BuyPrice != 40000 {
+		t.Errorf("expected the amount to change and the buy price to stay, got %d %+v", status, updated)
+	}
+
+	var list struct {
+		UserID   string             `json:"user_id"`
+		Holdings []models.Portfolio `json:"holdings"`
+		Count    int                `json:"count"`
+	}
+	if status := api.do(t, "GET", base, token, nil, &list); status != 200 || list.Count != 1 || list.Holdings[0].Amount != 0.75 {
+		t.Errorf("expected the updated holding to be listed, got %d %+v", status, list)
+	}
+
+	if status := api.do(t, "DELETE", base+"/bitcoin", token, nil, nil); status != 204 {
+		t.Errorf("expected the holding to be deleted, got %d", status)
+	}
+	if status := api.do(t, "GET", base+"/bitcoin", token, nil, &errBody); status != 404 || errBody.Code != apierror.CodeNotFound {
+		t.Errorf("expected the deleted holding to be gone, got %d %+v", status, errBody)
+	}
+	if status := api.do(t, "DELETE", base+"/bitcoin", token, nil, &errBody); status != 404 {
+		t.Errorf("expected deleting a missing holding to fail, got %d %+v", status, errBody)
+	}
+}
+
+func TestPriceHistoryPagination(t *testing.T) {
+	api := newTestAPI(t)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]models.PriceHistory, 0, 5)
+	for i := range 5 {
+		points = append(points, models.PriceHistory{TokenID: "bitcoin", Price: float64(100 + i), Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+	if err := api.stores.Prices.SavePrices(context.Background(), points); err != nil {
+		t.Fatalf("failed to save prices: %v", err)
+	}
+
+	type page struct {
+		Count      int                   `json:"count"`
+		History    []models.PriceHistory `json:"history"`
+		NextCursor string                `json:"next_cursor"`
+	}
+
+	// Pages of two walk the history newest first until the cursor runs out
+	prices := make([]float64, 0)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("expected the cursor to run out, got prices %v", prices)
+		}
+
+		query := url.Values{"limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var p page
+		if status := api.do(t, "GET", "/api/v1/history/bitcoin?"+query.Encode(), "", nil, &p); status != 200 || p.Count != len(p.History) {
+			t.Fatalf("expected a page, got %d %+v", status, p)
+		}
+		for _, point := range p.History {
+			prices = append(prices, point.Price)
+		}
+		if cursor = p.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	want := []float64{104, 103, 102, 101, 100}
+	if len(prices) != len(want) {
+		t.Fatalf("expected prices %v, got %v", want, prices)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Fatalf("expected prices %v, got %v", want, prices)
+		}
+	}
+
+	var errBody apiError
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"limits above the maximum are rejected", "/api/v1/history/bitcoin?limit=1001", 400},
+		{"foreign cursors are rejected", "/api/v1/history/bitcoin?cursor=not-a-cursor", 400},
+		{"tokens without history are not found", "/api/v1/history/ethereum", 404},
+	}
+	for _, tt := range tests {
+		if status := api.do(t, "GET", tt.path, "", nil, &errBody); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d %+v", tt.name, tt.status, status, errBody)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// queryInt reads an integer query parameter between min and max, using def
+// when it is absent. Values that are not integers are rejected, not truncated.
+func queryInt(c *fiber.Ctx, name string, def, min, max int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, apierror.Field(name, "must be an integer between %d and %d", min, max)
+	}
+	return n, nil
+}
+
+// parseTime accepts RFC3339 timestamps, YYYY-MM-DD dates or unix seconds
+func parseTime(field, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Time{}, apierror.Field(field, "invalid time '%s' (use RFC3339, YYYY-MM-DD or unix seconds)", value)
+}
+
+// parseTimeRange reads the from/to query values, defaulting to the window ending now
+func parseTimeRange(fromStr, toStr string, window time.Duration) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if toStr != "" {
+		t, err := parseTime("to", toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+
+	from := to.Add(-window)
+	if fromStr != "" {
+		t, err := parseTime("from", fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, apierror.Field("from", "must be before 'to'")
+	}
+	return from, to, nil
+}
+
+// parseStep parses a Go duration, also accepting whole days such as "1d"
+func parseStep(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, apierror.Field("step", "invalid step '%s'", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	step, err := time.ParseDuration(value)
+	if err != nil || step <= 0 {
+		return 0, apierror.Field("step", "invalid step '%s'", value)
+	}
+	return step, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// List all holdings of a user
+func (h *Handler) GetHoldings(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	holdings, err := h.Stores.Portfolios.GetHoldings(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch holdings")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":  userID,
+		"holdings": holdings,
+		"count":    len(holdings),
+	})
+}
+
+// Get a single holding of a user
+func (h *Handler) GetHolding(c *fiber.Ctx) error {
+	holding, err := h.Stores.Portfolios.GetHolding(c.Context(), c.Params("user"), c.Params("token"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Holding not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch holding")
+	}
+
+	return c.JSON(holding)
+}
+
+// Add a new holding to a user's portfolio
+func (h *Handler) AddHolding(c *fiber.Ctx) error {
+	var holding models.Portfolio
+	if err := c.BodyParser(&holding); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	holding.UserID = c.Params("user")
+	if err := validateHolding(holding); err != nil {
+		return err
+	}
+	if holding.BuyDate.IsZero() {
+		holding.BuyDate = time.Now()
+	}
+
+	_, err := h.Stores.Portfolios.GetHolding(c.Context(), holding.UserID, holding.TokenID)
+	if err == nil {
+		return apierror.Conflict("Holding already exists, use PUT to update it")
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		return apierror.Internal("Failed to fetch holding")
+	}
+
+	if err := h.Stores.Portfolios.SaveHolding(c.Context(), holding); err != nil {
+		return apierror.Internal("Failed to save holding")
+	}
+
+	return c.Status(201).JSON(holding)
+}
+
+// Update an existing holding of a user
+func (h *Handler) UpdateHolding(c *fiber.Ctx) error {
+	existing, err := h.Stores.Portfolios.GetHolding(c.Context(), c.Params("user"), c.Params("token"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Holding not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch holding")
+	}
+
+	// Fields missing from the body keep their stored values
+	holding := *existing
+	if err := c.BodyParser(&holding); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	holding.UserID = existing.UserID
+	holding.TokenID = existing.TokenID
+	if err := validateHolding(holding); err != nil {
+		return err
+	}
+
+	if err := h.Stores.Portfolios.SaveHolding(c.Context(), holding); err != nil {
+		return apierror.Internal("Failed to save holding")
+	}
+
+	return c.JSON(holding)
+}
+
+// validateHolding checks the fields of a new or updated holding
+func validateHolding(holding models.Portfolio) error {
+	var v apierror.Validator
+	v.Check(holding.TokenID != "", "token_id", "is required")
+	v.Check(holding.Amount > 0, "amount", "must be positive")
+	v.Check(holding.BuyPrice >= 0, "buy_price", "must be non-negative")
+	return v.Err()
+}
+
+// Remove a holding from a user's portfolio
+func (h *Handler) DeleteHolding(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	tokenID := c.Params("token")
+
+	_, err := h.Stores.Portfolios.GetHolding(c.Context(), userID, tokenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Holding not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch holding")
+	}
+
+	if err := h.Stores.Portfolios.DeleteHolding(c.Context(), userID, tokenID); err != nil {
+		return apierror.Internal("Failed to delete holding")
+	}
+
+	return c.SendStatus(204)
+}
+
+// Value a user's portfolio at current market prices
+func (h *Handler) GetPortfolioValuation(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
+
+	holdings, err := h.Stores.Portfolios.GetHoldings(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch holdings")
+	}
+
+	tokenIDs := make([]string, 0, len(holdings))
+	for _, holding := range holdings {
+		tokenIDs = append(tokenIDs, holding.TokenID)
+	}
+
+	prices, err := h.Stores.Tokens.GetTokenPrices(c.Context(), tokenIDs)
+	if err != nil {
+		return apierror.Internal("Failed to fetch token prices")
+	}
+
+	return c.JSON(conv.Valuation(services.ValuePortfolio(userID, holdings, prices)))
+}
+
+// maxHistoryPoints caps the number of steps of a portfolio history request
+const maxHistoryPoints = 2000
+
+// Get the total value of a user's portfolio over time
+func (h *Handler) GetPortfolioHistory(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	from, to, err := parseTimeRange(c.Query("from"), c.Query("to"), 30*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	step, err := parseStep(c.Query("step", "1d"))
+	if err != nil {
+		return err
+	}
+	if to.Sub(from)/step >= maxHistoryPoints {
+		return apierror.Field("step", "range and step produce more than %d points", maxHistoryPoints)
+	}
+
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+	services.SortTransactions(transactions)
+
+	holdings, err := h.Stores.Portfolios.GetHoldings(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch holdings")
+	}
+
+	timestamps := services.Steps(from, to, step)
+	amounts := services.AmountsAsOf(timestamps, transactions, holdings)
+
+	// Load the price series of every token held at any step
+	prices := make(map[string][]models.PriceHistory)
+	for _, snapshot := range amounts {
+		for tokenID := range snapshot {
+			if _, ok := prices[tokenID]; ok {
+				continue
+			}
+
+			points, err := h.Stores.Prices.GetPriceRange(c.Context(), tokenID, from, to)
+			if err != nil {
+				return apierror.Internal("Failed to fetch price history")
+			}
+
+			// Seed with the last point before the range so early steps have a
+			// value; it keeps its own time, so closer points in the range win
+			seed, err := h.Stores.Prices.GetPointAt(c.Context(), tokenID, from)
+			if err == nil {
+				points = append([]models.PriceHistory{*seed}, points...)
+			} else if !errors.Is(err, db.ErrNotFound) {
+				return apierror.Internal("Failed to fetch price history")
+			}
+
+			// Value each step in the requested currency as of that step
+			for i, point := range points {
+				points[i] = conv.PricePoint(point)
+			}
+			prices[tokenID] = points
+		}
+	}
+
+	series := services.ValueSeries(timestamps, amounts, prices)
+
+	return c.JSON(fiber.Map{
+		"user_id":  userID,
+		"from":     from,
+		"to":       to,
+		"step":     step.String(),
+		"currency": conv.Currency,
+		"count":    len(series),
+		"history":  series,
+	})
+}
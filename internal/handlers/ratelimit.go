@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/services"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit counts requests against the budget of a route class. Clients are
+// told their budget in X-RateLimit-* headers and get a 429 once it is spent.
+// It runs after Authenticate, so API keys and users are limited separately
+// from the IP address they call from.
+func (h *Handler) RateLimit(class services.RouteClass) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if h.Limiter == nil {
+			return c.Next()
+		}
+
+		quota, err := h.Limiter.Allow(c.Context(), class, rateLimitClient(c))
+		if err != nil {
+			// A failing counter must not take the API down with it
+			log.Printf("⚠️  Rate limit counter failed: %v", err)
+			return c.Next()
+		}
+
+		c.Set("X-RateLimit-Limit", strconv.Itoa(quota.Limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(quota.Reset.Unix(), 10))
+
+		if !quota.Allowed {
+			retryAfter := int(math.Ceil(time.Until(quota.Reset).Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(retryAfter, 1)))
+			return apierror.RateLimited("Rate limit exceeded, try again later")
+		}
+
+		return c.Next()
+	}
+}
+
+// RateLimitByMethod counts reads against the read budget and other requests
+// against the write budget, for route groups that mix both
+func (h *Handler) RateLimitByMethod(c *fiber.Ctx) error {
+	class := services.ClassWrite
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		class = services.ClassRead
+	}
+	return h.RateLimit(class)(c)
+}
+
+// rateLimitClient identifies whose budget a request spends
+func rateLimitClient(c *fiber.Ctx) string {
+	principal := currentPrincipal(c)
+	switch {
+	case principal.APIKeyID != "":
+		return "key:" + principal.APIKeyID
+	case principal.UserID != "":
+		return "user:" + principal.UserID
+	default:
+		return "ip:" + c.IP()
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamHeartbeat keeps idle connections from being closed by proxies
+const streamHeartbeat = 15 * time.Second
+
+// streamRequest is what a stream client subscribed to: a list of tokens, a
+// user's portfolio, or every token when neither is given
+type streamRequest struct {
+	tokenIDs []string
+	userID   string
+	conv     services.Converter
+}
+
+// Stream price updates over WebSocket, or Server-Sent Events for other requests
+func (h *Handler) StreamPrices(c *fiber.Ctx) error {
+	req := streamRequest{userID: c.Query("portfolio")}
+	if ids := c.Query("tokens"); ids != "" {
+		req.tokenIDs = strings.Split(ids, ",")
+	}
+	if req.userID != "" && len(req.tokenIDs) > 0 {
+		return apierror.Field("portfolio", "cannot be combined with 'tokens'")
+	}
+
+	// Browsers cannot set headers on EventSource and WebSocket requests, so
+	// portfolio streams also accept the access token as a query parameter
+	principal := currentPrincipal(c)
+	if req.userID != "" {
+		if principal.UserID == "" {
+			var err error
+			if principal, err = h.Auth.Authenticate(c.Query("access_token")); err != nil {
+				return unauthorized(c)
+			}
+		}
+		if !canAccessUser(principal, req.userID) {
+			return apierror.Forbidden("Access to this user's data is not allowed")
+		}
+		if !principal.HasScope(models.ScopeReadPortfolio) {
+			return scopeError(models.ScopeReadPortfolio)
+		}
+	} else if !principal.HasScope(models.ScopeReadMarket) {
+		return scopeError(models.ScopeReadMarket)
+	}
+
+	conv, err := h.converter(c)
+	if err != nil {
+		return err
+	}
+	req.conv = conv
+
+	if websocket.IsWebSocketUpgrade(c) {
+		return websocket.New(func(conn *websocket.Conn) {
+			h.streamWebSocket(conn, req)
+		})(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The writer runs after the handler returns, so it must not use c
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		h.streamSSE(w, req)
+	})
+	return nil
+}
+
+func (h *Handler) streamSSE(w *bufio.Writer, req streamRequest) {
+	send := func(msg services.StreamMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+		return w.Flush()
+	}
+	heartbeat := func() error {
+		w.WriteString(": heartbeat\n\n")
+		return w.Flush()
+	}
+
+	h.runStream(context.Background(), req, send, heartbeat)
+}
+
+func (h *Handler) streamWebSocket(conn *websocket.Conn, req streamRequest) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Reading is needed to notice the client closing the connection
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(msg services.StreamMessage) error {
+		return conn.WriteJSON(msg)
+	}
+	heartbeat := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamHeartbeat))
+	}
+
+	h.runStream(ctx, req, send, heartbeat)
+}
+
+// runStream sends a snapshot of the subscribed prices, then every update,
+// until sending fails, ctx is done or the hub closes the subscription
+func (h *Handler) runStream(ctx context.Context, req streamRequest, send func(services.StreamMessage) error, heartbeat func() error) {
+	sub := h.Stream.Subscribe(req.tokenIDs)
+	defer func() {
+		h.Stream.Unsubscribe(sub)
+		if dropped := sub.Dropped(); dropped > 0 {
+			log.Printf("⚠️  Stream subscriber fell behind, %d updates dropped", dropped)
+		}
+	}()
+
+	snapshot, err := h.streamSnapshot(ctx, req)
+	if err != nil {
+		log.Printf("❌ Failed to load stream snapshot: %v", err)
+		return
+	}
+	if msg, ok, err := h.streamMessage(ctx, req, snapshot); err != nil || (ok && send(msg) != nil) {
+		return
+	}
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case update, open := <-sub.C():
+			if !open {
+				return
+			}
+			msg, ok, err := h.streamMessage(ctx, req, update)
+			if err != nil {
+				log.Printf("❌ Failed to build stream update: %v", err)
+				continue
+			}
+			if ok && send(msg) != nil {
+				return
+			}
+		case <-ticker.C:
+			if heartbeat() != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// streamSnapshot returns the stored prices a client sees on connecting
+func (h *Handler) streamSnapshot(ctx context.Context, req streamRequest) (services.StreamMessage, error) {
+	msg := services.StreamMessage{Type: services.StreamPrices, Time: time.Now()}
+
+	if len(req.tokenIDs) == 0 {
+		tokens, err := h.Stores.Tokens.ListTokens(ctx)
+		msg.Tokens = tokens
+		return msg, err
+	}
+
+	msg.Tokens = make([]models.Token, 0, len(req.tokenIDs))
+	for _, id := range req.tokenIDs {
+		token, err := h.Stores.Tokens.GetToken(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return msg, err
+		}
+		msg.Tokens = append(msg.Tokens, *token)
+	}
+	return msg, nil
+}
+
+// streamMessage converts an update for a client. Portfolio clients get the
+// tokens they hold and their revalued portfolio; false means nothing to send.
+func (h *Handler) streamMessage(ctx context.Context, req streamRequest, update services.StreamMessage) (services.StreamMessage, bool, error) {
+	if req.userID == "" {
+		tokens := make([]models.Token, len(update.Tokens))
+		for i, token := range update.Tokens {
+			tokens[i] = req.conv.Token(token)
+		}
+		update.Tokens = tokens
+		return update, true, nil
+	}
+
+	// Positions are read on every update so new transactions show up on open streams
+	holdings, err := services.CurrentHoldings(ctx, h.Stores, req.userID)
+	if err != nil {
+		return update, false, err
+	}
+
+	held := make(map[string]bool, len(holdings))
+	tokenIDs := make([]string, 0, len(holdings))
+	for _, holding := range holdings {
+		held[holding.TokenID] = true
+		tokenIDs = append(tokenIDs, holding.TokenID)
+	}
+
+	tokens := make([]models.Token, 0)
+	for _, token := range update.Tokens {
+		if held[token.ID] {
+			tokens = append(tokens, req.conv.Token(token))
+		}
+	}
+	if len(tokens) == 0 {
+		return update, false, nil
+	}
+
+	prices, err := h.Stores.Tokens.GetTokenPrices(ctx, tokenIDs)
+	if err != nil {
+		return update, false, err
+	}
+	for _, token := range update.Tokens {
+		if held[token.ID] {
+			prices[token.ID] = token.CurrentPrice
+		}
+	}
+	valuation := req.conv.Valuation(services.ValuePortfolio(req.userID, holdings, prices))
+
+	update.Type = services.StreamPortfolio
+	update.Tokens = tokens
+	update.Portfolio = &valuation
+	return update, true, nil
+}
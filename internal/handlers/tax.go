@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/costbasis"
+	"crypto-portfolio-tracker/internal/services"
+	"crypto-portfolio-tracker/internal/tax"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Get a user's yearly capital gains report as JSON or CSV
+func (h *Handler) GetTaxReport(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	year, err := strconv.Atoi(c.Params("year"))
+	if err != nil || year < 1970 || year > 9999 {
+		return apierror.Field("year", "must be a year between 1970 and 9999")
+	}
+
+	method, err := costbasis.ParseMethod(c.Query("method", string(costbasis.FIFO)))
+	if err != nil {
+		return apierror.Field("method", "%s", err.Error())
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return apierror.Field("format", "must be 'json' or 'csv'")
+	}
+
+	report, err := services.BuildTaxReport(c.Context(), h.Stores, userID, year, method)
+	if errors.Is(err, services.ErrUnreportable) {
+		return apierror.Unprocessable(err.Error())
+	}
+	if err != nil {
+		return apierror.Internal("Failed to build tax report")
+	}
+
+	if format == "json" {
+		return c.JSON(report)
+	}
+
+	var buf bytes.Buffer
+	if err := tax.WriteCSV(&buf, report); err != nil {
+		return apierror.Internal("Failed to write CSV")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="capital-gains-%s-%d.csv"`, userID, year))
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/costbasis"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// List a user's ledger, optionally filtered by token
+func (h *Handler) GetTransactions(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	tokenID := c.Query("token", "")
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+
+	if tokenID != "" {
+		filtered := make([]models.Transaction, 0)
+		for _, tx := range transactions {
+			if tx.TokenID == tokenID {
+				filtered = append(filtered, tx)
+			}
+		}
+		transactions = filtered
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":      userID,
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
+// Record a buy, sell, deposit, withdraw, transfer or fee event
+func (h *Handler) AddTransaction(c *fiber.Ctx) error {
+	var tx models.Transaction
+	if err := c.BodyParser(&tx); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	tx.ID = ""
+	tx.UserID = c.Params("user")
+	if tx.Wallet == "" {
+		tx.Wallet = services.DefaultWallet
+	}
+	if tx.Timestamp.IsZero() {
+		tx.Timestamp = time.Now()
+	}
+
+	if err := services.ValidateTransaction(tx); err != nil {
+		return err
+	}
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), tx.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+
+	// Reject events that would leave a wallet with a negative balance
+	transactions = append(transactions, tx)
+	services.SortTransactions(transactions)
+	if _, err := services.ReplayLedger(transactions); err != nil {
+		return apierror.Conflict(err.Error())
+	}
+
+	if err := h.Stores.Transactions.SaveTransaction(c.Context(), &tx); err != nil {
+		return apierror.Internal("Failed to save transaction")
+	}
+
+	return c.Status(201).JSON(tx)
+}
+
+// Remove a transaction from a user's ledger
+func (h *Handler) DeleteTransaction(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	id := c.Params("id")
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+
+	remaining := make([]models.Transaction, 0, len(transactions))
+	found := false
+	for _, tx := range transactions {
+		if tx.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, tx)
+	}
+	if !found {
+		return apierror.NotFound("Transaction not found")
+	}
+
+	// Removing an acquisition must not invalidate later disposals
+	services.SortTransactions(remaining)
+	if _, err := services.ReplayLedger(remaining); err != nil {
+		return apierror.Conflict(err.Error())
+	}
+
+	err = h.Stores.Transactions.DeleteTransaction(c.Context(), userID, id)
+	if errors.Is(err, db.ErrInvalidID) {
+		return apierror.BadRequest("Invalid transaction ID")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to delete transaction")
+	}
+
+	return c.SendStatus(204)
+}
+
+// Get a user's current positions derived from the ledger
+func (h *Handler) GetPositions(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+
+	services.SortTransactions(transactions)
+	positions, err := services.ReplayLedger(transactions)
+	if err != nil {
+		return apierror.Conflict(err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":   userID,
+		"positions": positions,
+		"count":     len(positions),
+	})
+}
+
+// Get realized gains of a user's ledger using a cost basis method
+func (h *Handler) GetRealizedGains(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	method, err := costbasis.ParseMethod(c.Query("method", string(costbasis.FIFO)))
+	if err != nil {
+		return apierror.Field("method", "%s", err.Error())
+	}
+
+	transactions, err := h.Stores.Transactions.GetTransactions(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch transactions")
+	}
+
+	services.SortTransactions(transactions)
+	result, err := costbasis.Calculate(transactions, method)
+	if err != nil {
+		return apierror.Conflict(err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":  userID,
+		"realized": result,
+	})
+}
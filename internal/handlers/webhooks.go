@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto-portfolio-tracker/internal/services"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxDeliveries bounds a page of the webhook delivery log
+const maxDeliveries = 1000
+
+// List a user's webhooks
+func (h *Handler) GetWebhooks(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	hooks, err := h.Stores.Webhooks.GetWebhooks(c.Context(), userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch webhooks")
+	}
+
+	// Secrets are only shown when a webhook is created
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":  userID,
+		"webhooks": hooks,
+		"count":    len(hooks),
+	})
+}
+
+// Get a single webhook of a user
+func (h *Handler) GetWebhook(c *fiber.Ctx) error {
+	hook, err := h.Stores.Webhooks.GetWebhook(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Webhook not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch webhook")
+	}
+
+	hook.Secret = ""
+	return c.JSON(hook)
+}
+
+// Register a webhook endpoint; the response holds the signing secret
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	var hook models.Webhook
+	if err := c.BodyParser(&hook); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	hook.ID = ""
+	hook.UserID = c.Params("user")
+	hook.CreatedAt = time.Now()
+
+	if err := services.ValidateWebhook(&hook); err != nil {
+		return err
+	}
+
+	if err := h.Stores.Webhooks.SaveWebhook(c.Context(), &hook); err != nil {
+		return apierror.Internal("Failed to save webhook")
+	}
+
+	return c.Status(201).JSON(hook)
+}
+
+// Delete a webhook of a user; its pending deliveries are abandoned
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	userID := c.Params("user")
+	id := c.Params("id")
+
+	_, err := h.Stores.Webhooks.GetWebhook(c.Context(), userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Webhook not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch webhook")
+	}
+
+	if err := h.Stores.Webhooks.DeleteWebhook(c.Context(), userID, id); err != nil {
+		return apierror.Internal("Failed to delete webhook")
+	}
+
+	return c.SendStatus(204)
+}
+
+// Get a user's webhook delivery log, newest first
+func (h *Handler) GetDeliveries(c *fiber.Ctx) error {
+	userID := c.Params("user")
+
+	limit, err := queryInt(c, "limit", 100, 1, maxDeliveries)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := h.Stores.Webhooks.GetDeliveries(c.Context(), userID, limit)
+	if err != nil {
+		return apierror.Internal("Failed to fetch deliveries")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":    userID,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// Get a single webhook delivery of a user
+func (h *Handler) GetDelivery(c *fiber.Ctx) error {
+	delivery, err := h.Stores.Webhooks.GetDelivery(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Delivery not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch delivery")
+	}
+
+	return c.JSON(delivery)
+}
+
+// Queue the payload of a delivery to be sent again as a new delivery
+func (h *Handler) Redeliver(c *fiber.Ctx) error {
+	previous, err := h.Stores.Webhooks.GetDelivery(c.Context(), c.Params("user"), c.Params("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound("Delivery not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch delivery")
+	}
+
+	delivery, err := h.Webhooks.Redeliver(c.Context(), *previous)
+	if errors.Is(err, db.ErrNotFound) {
+		return apierror.Conflict("The webhook of this delivery was deleted")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to redeliver")
+	}
+
+	return c.Status(202).JSON(delivery)
+}
@@ -1,23 +1,44 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// Token represents a cryptocurrency token
+// Token represents a cryptocurrency token. Prices are in USD unless Currency
+// is set; Quotes holds the price in each configured quote currency.
 type Token struct {
-	ID           string    `json:"id"`
-	Symbol       string    `json:"symbol"`
-	Name         string    `json:"name"`
-	CurrentPrice float64   `json:"current_price"`
-	MarketCap    float64   `json:"market_cap"`
-	Volume24h    float64   `json:"volume_24h"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string             `json:"id"`
+	Symbol       string             `json:"symbol"`
+	Name         string             `json:"name"`
+	CurrentPrice float64            `json:"current_price"`
+	MarketCap    float64            `json:"market_cap"`
+	Volume24h    float64            `json:"volume_24h"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	Sources      []string           `json:"sources,omitempty"`
+	Quotes       map[string]float64 `json:"quotes,omitempty"`
+	Currency     string             `json:"currency,omitempty"`
 }
 
 // PriceHistory stores historical price data
 type PriceHistory struct {
-	TokenID   string    `json:"token_id"`
-	Price     float64   `json:"price"`
-	Timestamp time.Time `json:"timestamp"`
+	TokenID   string             `json:"token_id"`
+	Price     float64            `json:"price"`
+	Quotes    map[string]float64 `json:"quotes,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// Candle summarizes the prices of a token within one interval bucket.
+// OpenTime and CloseTime are the timestamps of the first and last price.
+type Candle struct {
+	Start     time.Time `json:"start"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Count     int       `json:"count"`
+	OpenTime  time.Time `json:"open_time"`
+	CloseTime time.Time `json:"close_time"`
 }
 
 // Portfolio represents user's crypto holdings
@@ -28,3 +49,230 @@ type Portfolio struct {
 	BuyPrice float64   `json:"buy_price"`
 	BuyDate  time.Time `json:"buy_date"`
 }
+
+// HoldingValuation is a single position marked to the current market price
+type HoldingValuation struct {
+	TokenID          string  `json:"token_id"`
+	Amount           float64 `json:"amount"`
+	BuyPrice         float64 `json:"buy_price"`
+	CurrentPrice     float64 `json:"current_price"`
+	MarketValue      float64 `json:"market_value"`
+	CostBasis        float64 `json:"cost_basis"`
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`
+	UnrealizedPnLPct float64 `json:"unrealized_pnl_pct"`
+	PriceAvailable   bool    `json:"price_available"`
+}
+
+// PortfolioValuation aggregates the valuation of all holdings of a user
+type PortfolioValuation struct {
+	UserID           string             `json:"user_id"`
+	Holdings         []HoldingValuation `json:"holdings"`
+	TotalValue       float64            `json:"total_value"`
+	TotalCostBasis   float64            `json:"total_cost_basis"`
+	UnrealizedPnL    float64            `json:"unrealized_pnl"`
+	UnrealizedPnLPct float64            `json:"unrealized_pnl_pct"`
+	MissingPrices    []string           `json:"missing_prices"`
+	Currency         string             `json:"currency"`
+	ValuedAt         time.Time          `json:"valued_at"`
+}
+
+// TransactionType is the kind of event recorded in a portfolio ledger
+type TransactionType string
+
+const (
+	TransactionBuy      TransactionType = "buy"
+	TransactionSell     TransactionType = "sell"
+	TransactionDeposit  TransactionType = "deposit"
+	TransactionWithdraw TransactionType = "withdraw"
+	TransactionTransfer TransactionType = "transfer"
+	TransactionFee      TransactionType = "fee"
+)
+
+// Transaction is a single ledger event of a user's portfolio.
+// Price and Fee are quoted in USD; Amount is in units of the token.
+type Transaction struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	TokenID   string          `json:"token_id"`
+	Type      TransactionType `json:"type"`
+	Amount    float64         `json:"amount"`
+	Price     float64         `json:"price"`
+	Fee       float64         `json:"fee"`
+	Wallet    string          `json:"wallet"`
+	ToWallet  string          `json:"to_wallet,omitempty"`
+	Note      string          `json:"note,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Position is a user's holding of a token derived from replaying the ledger
+type Position struct {
+	TokenID   string             `json:"token_id"`
+	Amount    float64            `json:"amount"`
+	CostBasis float64            `json:"cost_basis"`
+	AvgCost   float64            `json:"avg_cost"`
+	Wallets   map[string]float64 `json:"wallets"`
+}
+
+// PortfolioValuePoint is the total value of a portfolio at a point in time
+type PortfolioValuePoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Value         float64   `json:"value"`
+	MissingPrices []string  `json:"missing_prices,omitempty"`
+}
+
+// BackfillStatus is the state of a historical price backfill
+type BackfillStatus string
+
+const (
+	BackfillRunning   BackfillStatus = "running"
+	BackfillCompleted BackfillStatus = "completed"
+	BackfillFailed    BackfillStatus = "failed"
+)
+
+// BackfillState records the progress of a token's backfill. Windows before
+// ResumeAt have been written, so a failed or interrupted backfill resumes there.
+type BackfillState struct {
+	TokenID   string         `json:"token_id"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	ResumeAt  time.Time      `json:"resume_at"`
+	Points    int            `json:"points"`
+	Status    BackfillStatus `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// AlertType is the condition an alert watches
+type AlertType string
+
+const (
+	// AlertPriceAbove fires when a token's price rises to Threshold or above
+	AlertPriceAbove AlertType = "price_above"
+	// AlertPriceBelow fires when a token's price falls to Threshold or below
+	AlertPriceBelow AlertType = "price_below"
+	// AlertPercentChange fires when a token's price moves by Threshold percent
+	// within Window; a negative threshold watches for drops
+	AlertPercentChange AlertType = "percent_change"
+	// AlertPortfolioBelow fires when the value of the user's holdings falls to Threshold or below
+	AlertPortfolioBelow AlertType = "portfolio_below"
+)
+
+// AlertState keeps an alert from firing again while its condition persists
+type AlertState string
+
+const (
+	// AlertArmed alerts fire as soon as their condition holds
+	AlertArmed AlertState = "armed"
+	// AlertTriggered alerts have fired and wait for their condition to clear
+	AlertTriggered AlertState = "triggered"
+	// AlertCooldown alerts are re-armed once CooldownUntil has passed
+	AlertCooldown AlertState = "cooldown"
+)
+
+// Alert is a user's rule on token prices or portfolio value. Window and
+// Cooldown are durations such as "1h"; prices are in USD.
+type Alert struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	Type          AlertType  `json:"type"`
+	TokenID       string     `json:"token_id,omitempty"`
+	Threshold     float64    `json:"threshold"`
+	Window        string     `json:"window,omitempty"`
+	Cooldown      string     `json:"cooldown"`
+	State         AlertState `json:"state"`
+	TriggeredAt   *time.Time `json:"triggered_at,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// AlertEvent records an alert firing. Value is the observed price, percent
+// change or portfolio value.
+type AlertEvent struct {
+	AlertID     string    `json:"alert_id"`
+	UserID      string    `json:"user_id"`
+	Type        AlertType `json:"type"`
+	TokenID     string    `json:"token_id,omitempty"`
+	Threshold   float64   `json:"threshold"`
+	Value       float64   `json:"value"`
+	Message     string    `json:"message"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// WebhookEventAlertTriggered is sent when one of the user's alerts fires
+const WebhookEventAlertTriggered = "alert.triggered"
+
+// Webhook is an endpoint that receives a user's events. Secret keys the
+// HMAC-SHA256 signature of each delivery.
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeliveryStatus is the outcome of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery records sending one event to one webhook. Pending
+// deliveries are retried at NextAttemptAt.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	UserID        string          `json:"user_id"`
+	Event         string          `json:"event"`
+	URL           string          `json:"url"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// Role separates regular users from administrators of shared market data
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// User is an account. The username is also the user ID of portfolio routes.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// API key scopes
+const (
+	ScopeReadMarket     = "read:market"
+	ScopeReadPortfolio  = "read:portfolio"
+	ScopeWritePortfolio = "write:portfolio"
+	ScopeAdminSync      = "admin:sync"
+)
+
+// APIKey grants a script access on behalf of a user, limited to its scopes.
+// Only a hash of the key is stored; the key is shown once when created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Consensus methods combining provider quotes into one price
+const (
+	ConsensusMedian = "median"
+	ConsensusVWAP   = "vwap"
+)
+
+// Quote is a single provider's price for a token
+type Quote struct {
+	Source string
+	Price  float64
+	Volume float64
+}
+
+// AggregatingProvider queries every provider concurrently and replaces each
+// token's price with a consensus of the quotes that agree with the median
+type AggregatingProvider struct {
+	Providers    []PriceProvider
+	Method       string
+	MaxDeviation float64
+	MinSources   int
+}
+
+func (a *AggregatingProvider) Name() string {
+	return "aggregate"
+}
+
+// FetchTopTokens takes the token list and metadata from the first provider that
+// answers, then prices those tokens with quotes from every other provider
+func (a *AggregatingProvider) FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error) {
+	var base []models.Token
+	var baseSource string
+	var errs []error
+	for _, p := range a.Providers {
+		tokens, err := p.FetchTopTokens(ctx, limit)
+		if err == nil && len(tokens) > 0 {
+			base, baseSource = tokens, p.Name()
+			break
+		}
+		if err != nil {
+			log.Printf("⚠️  Price provider %s failed: %v", p.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	if base == nil {
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no price provider returned data")
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	// Top lists differ between providers, so ask the others for the base tokens by ID
+	ids := make([]string, 0, len(base))
+	for _, token := range base {
+		ids = append(ids, token.ID)
+	}
+	results := a.fetchAll(func(p PriceProvider) ([]models.Token, error) {
+		if p.Name() == baseSource {
+			return base, nil
+		}
+		return p.FetchTokens(ctx, ids)
+	})
+
+	return a.merge(base, results), nil
+}
+
+// FetchTokens prices the given tokens with quotes from every provider
+func (a *AggregatingProvider) FetchTokens(ctx context.Context, ids []string) ([]models.Token, error) {
+	results := a.fetchAll(func(p PriceProvider) ([]models.Token, error) {
+		return p.FetchTokens(ctx, ids)
+	})
+
+	// Metadata comes from the first provider (in configured order) that knows the token
+	seen := make(map[string]bool)
+	base := make([]models.Token, 0, len(ids))
+	for _, r := range results {
+		for _, token := range r.tokens {
+			if !seen[token.ID] {
+				seen[token.ID] = true
+				base = append(base, token)
+			}
+		}
+	}
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+	if failed == len(results) {
+		return nil, joinErrors(results)
+	}
+
+	return a.merge(base, results), nil
+}
+
+// FetchHistory uses the first provider able to serve the series
+func (a *AggregatingProvider) FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error) {
+	return fallback(a.Providers, func(p PriceProvider) ([]models.PriceHistory, error) {
+		return p.FetchHistory(ctx, id, from, to)
+	})
+}
+
+type providerResult struct {
+	source string
+	tokens []models.Token
+	err    error
+}
+
+// fetchAll runs fetch against every provider in parallel, keeping the configured order
+func (a *AggregatingProvider) fetchAll(fetch func(PriceProvider) ([]models.Token, error)) []providerResult {
+	results := make([]providerResult, len(a.Providers))
+
+	var wg sync.WaitGroup
+	for i, p := range a.Providers {
+		wg.Add(1)
+		go func(i int, p PriceProvider) {
+			defer wg.Done()
+			tokens, err := fetch(p)
+			if err != nil {
+				log.Printf("⚠️  Price provider %s failed: %v", p.Name(), err)
+			}
+			results[i] = providerResult{source: p.Name(), tokens: tokens, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// joinErrors combines the provider errors, or reports that none returned data
+func joinErrors(results []providerResult) error {
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.source, r.err))
+		}
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no price provider returned data")
+	}
+	return errors.Join(errs...)
+}
+
+// merge sets each base token's price to the consensus of all quotes for it.
+// Tokens with fewer agreeing sources than MinSources are dropped.
+func (a *AggregatingProvider) merge(base []models.Token, results []providerResult) []models.Token {
+	quotes := make(map[string][]Quote)
+	for _, r := range results {
+		for _, token := range r.tokens {
+			quotes[token.ID] = append(quotes[token.ID], Quote{
+				Source: r.source,
+				Price:  token.CurrentPrice,
+				Volume: token.Volume24h,
+			})
+		}
+	}
+
+	tokens := make([]models.Token, 0, len(base))
+	for _, token := range base {
+		price, accepted, rejected := Consensus(quotes[token.ID], a.Method, a.MaxDeviation)
+		for _, q := range rejected {
+			log.Printf("⚠️  Rejected %s quote for %s: %g deviates from median", q.Source, token.ID, q.Price)
+		}
+		if len(accepted) == 0 || len(accepted) < a.MinSources {
+			log.Printf("⚠️  Skipping %s: %d of %d required sources agree", token.ID, len(accepted), a.MinSources)
+			continue
+		}
+
+		token.CurrentPrice = price
+		token.Sources = make([]string, 0, len(accepted))
+		for _, q := range accepted {
+			token.Sources = append(token.Sources, q.Source)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+// Consensus combines quotes into one price. Quotes deviating from the median by
+// more than maxDeviation (a fraction of the median) are rejected first; the
+// remaining quotes are combined by their median or volume-weighted average.
+// When all quotes disagree, none is accepted and the price is 0.
+func Consensus(quotes []Quote, method string, maxDeviation float64) (float64, []Quote, []Quote) {
+	valid := make([]Quote, 0, len(quotes))
+	for _, q := range quotes {
+		if q.Price > 0 && !math.IsInf(q.Price, 0) && !math.IsNaN(q.Price) {
+			valid = append(valid, q)
+		}
+	}
+	if len(valid) == 0 {
+		return 0, nil, quotes
+	}
+
+	mid := median(valid)
+
+	accepted := make([]Quote, 0, len(valid))
+	rejected := make([]Quote, 0)
+	for _, q := range valid {
+		if math.Abs(q.Price-mid)/mid > maxDeviation {
+			rejected = append(rejected, q)
+			continue
+		}
+		accepted = append(accepted, q)
+	}
+
+	// With no majority every quote can be too far from the median
+	if len(accepted) == 0 {
+		return 0, nil, valid
+	}
+
+	if method == ConsensusVWAP {
+		weighted, volume := 0.0, 0.0
+		for _, q := range accepted {
+			weighted += q.Price * q.Volume
+			volume += q.Volume
+		}
+		if volume > 0 {
+			return weighted / volume, accepted, rejected
+		}
+	}
+
+	return median(accepted), accepted, rejected
+}
+
+func median(quotes []Quote) float64 {
+	prices := make([]float64, len(quotes))
+	for i, q := range quotes {
+		prices[i] = q.Price
+	}
+	sort.Float64s(prices)
+
+	n := len(prices)
+	if n%2 == 1 {
+		return prices[n/2]
+	}
+	return (prices[n/2-1] + prices[n/2]) / 2
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSymbols = map[string]string{"bitcoin": "BTC"}
+
+// binanceServer answers 24h ticker requests for BTCUSDT at price
+func binanceServer(t *testing.T, price, volume float64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/ticker/24hr" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"symbol": "BTCUSDT", "lastPrice": "%g", "quoteVolume": "%g"}]`, price, volume)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// krakenServer answers ticker requests for XBTUSDT at price
+func krakenServer(t *testing.T, price, volume float64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/Ticker" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"error": [], "result": {"XBTUSDT": {"c": ["%g", "1"], "v": ["1", "%g"]}}}`, price, volume/price)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// failingServer answers every request with a status
+func failingServer(t *testing.T, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func exchange(server *httptest.Server) config.ExchangeConfig {
+	return config.ExchangeConfig{BaseURL: server.URL, QuoteAsset: "USDT", Timeout: 5 * time.Second}
+}
+
+func TestAggregatingProviderFetchTokens(t *testing.T) {
+	tests := []struct {
+		name         string
+		binance      *httptest.Server
+		kraken       *httptest.Server
+		method       string
+		maxDeviation float64
+		minSources   int
+		price        float64
+		sources      []string
+	}{
+		{
+			name:         "agreeing sources give their median",
+			binance:      binanceServer(t, 100, 1000),
+			kraken:       krakenServer(t, 101, 1000),
+			method:       ConsensusMedian,
+			maxDeviation: 0.02,
+			minSources:   2,
+			price:        100.5,
+			sources:      []string{"binance", "kraken"},
+		},
+		{
+			name:         "agreeing sources weighted by volume",
+			binance:      binanceServer(t, 100, 3000),
+			kraken:       krakenServer(t, 101, 1000),
+			method:       ConsensusVWAP,
+			maxDeviation: 0.02,
+			minSources:   2,
+			price:        100.25,
+			sources:      []string{"binance", "kraken"},
+		},
+		{
+			name:         "all sources disagree",
+			binance:      binanceServer(t, 100, 1000),
+			kraken:       krakenServer(t, 150, 1000),
+			method:       ConsensusMedian,
+			maxDeviation: 0.05,
+			minSources:   1,
+		},
+		{
+			name:         "all sources disagree without volume",
+			binance:      binanceServer(t, 100, 0),
+			kraken:       krakenServer(t, 150, 0),
+			method:       ConsensusVWAP,
+			maxDeviation: 0.05,
+			minSources:   1,
+		},
+		{
+			name:         "a failing source leaves the others",
+			binance:      failingServer(t, http.StatusBadRequest),
+			kraken:       krakenServer(t, 101, 1000),
+			method:       ConsensusMedian,
+			maxDeviation: 0.02,
+			minSources:   1,
+			price:        101,
+			sources:      []string{"kraken"},
+		},
+		{
+			name:         "too few sources",
+			binance:      failingServer(t, http.StatusBadRequest),
+			kraken:       krakenServer(t, 101, 1000),
+			method:       ConsensusMedian,
+			maxDeviation: 0.02,
+			minSources:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator := &AggregatingProvider{
+				Providers: []PriceProvider{
+					NewBinanceClient(exchange(tt.binance), testSymbols),
+					NewKrakenClient(exchange(tt.kraken), testSymbols),
+				},
+				Method:       tt.method,
+				MaxDeviation: tt.maxDeviation,
+				MinSources:   tt.minSources,
+			}
+
+			tokens, err := aggregator.FetchTokens(context.Background(), []string{"bitcoin"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.sources == nil {
+				if len(tokens) != 0 {
+					t.Fatalf("expected bitcoin to be skipped, got %+v", tokens)
+				}
+				return
+			}
+			if len(tokens) != 1 {
+				t.Fatalf("expected one token, got %+v", tokens)
+			}
+			if math.Abs(tokens[0].CurrentPrice-tt.price) > 1e-9 {
+				t.Errorf("expected price %g, got %g", tt.price, tokens[0].CurrentPrice)
+			}
+			if fmt.Sprint(tokens[0].Sources) != fmt.Sprint(tt.sources) {
+				t.Errorf("expected sources %v, got %v", tt.sources, tokens[0].Sources)
+			}
+		})
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	tests := []struct {
+		name     string
+		quotes   []Quote
+		method   string
+		price    float64
+		accepted int
+		rejected int
+	}{
+		{
+			name:     "outlier is rejected",
+			quotes:   []Quote{{"a", 100, 1}, {"b", 101, 1}, {"c", 130, 1}},
+			method:   ConsensusMedian,
+			price:    100.5,
+			accepted: 2,
+			rejected: 1,
+		},
+		{
+			name:     "volume weighted",
+			quotes:   []Quote{{"a", 100, 3}, {"b", 101, 1}},
+			method:   ConsensusVWAP,
+			price:    100.25,
+			accepted: 2,
+		},
+		{
+			name:     "volume weighted without volume falls back to the median",
+			quotes:   []Quote{{"a", 100, 0}, {"b", 101, 0}},
+			method:   ConsensusVWAP,
+			price:    100.5,
+			accepted: 2,
+		},
+		{
+			name:     "invalid prices are rejected",
+			quotes:   []Quote{{"a", 0, 1}, {"b", math.NaN(), 1}, {"c", 100, 1}},
+			method:   ConsensusMedian,
+			price:    100,
+			accepted: 1,
+		},
+		{
+			name:     "all quotes disagree",
+			quotes:   []Quote{{"a", 100, 1}, {"b", 150, 1}},
+			method:   ConsensusMedian,
+			rejected: 2,
+		},
+		{
+			name:     "all quotes disagree without volume",
+			quotes:   []Quote{{"a", 100, 0}, {"b", 150, 0}},
+			method:   ConsensusVWAP,
+			rejected: 2,
+		},
+		{
+			name:     "no quotes",
+			method:   ConsensusMedian,
+			rejected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, accepted, rejected := Consensus(tt.quotes, tt.method, 0.05)
+			if math.Abs(price-tt.price) > 1e-9 {
+				t.Errorf("expected price %g, got %g", tt.price, price)
+			}
+			if len(accepted) != tt.accepted || len(rejected) != tt.rejected {
+				t.Errorf("expected %d accepted and %d rejected, got %v and %v", tt.accepted, tt.rejected, accepted, rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultAlertCooldown is used for alerts that don't set a cooldown
+const DefaultAlertCooldown = "1h"
+
+// maxAlertWindow bounds percent-change windows to history that is usually retained
+const maxAlertWindow = 30 * 24 * time.Hour
+
+// ValidateAlert checks that an alert rule is well formed and fills in the default cooldown
+func ValidateAlert(alert *models.Alert) error {
+	var v apierror.Validator
+	switch alert.Type {
+	case models.AlertPriceAbove, models.AlertPriceBelow:
+		v.Check(alert.TokenID != "", "token_id", "is required for %s alerts", alert.Type)
+		v.Check(alert.Threshold > 0, "threshold", "must be a positive price")
+		v.Check(alert.Window == "", "window", "only applies to %s alerts", models.AlertPercentChange)
+	case models.AlertPercentChange:
+		v.Check(alert.TokenID != "", "token_id", "is required for %s alerts", alert.Type)
+		v.Check(alert.Threshold != 0, "threshold", "must be a non-zero percentage, negative for drops")
+		window, err := time.ParseDuration(alert.Window)
+		v.Check(err == nil && window > 0 && window <= maxAlertWindow,
+			"window", "must be a duration such as '1h', up to %v", maxAlertWindow)
+	case models.AlertPortfolioBelow:
+		v.Check(alert.TokenID == "", "token_id", "does not apply to %s alerts", alert.Type)
+		v.Check(alert.Threshold > 0, "threshold", "must be a positive portfolio value")
+		v.Check(alert.Window == "", "window", "only applies to %s alerts", models.AlertPercentChange)
+	default:
+		v.Check(false, "type", "unknown alert type '%s'", alert.Type)
+	}
+
+	if alert.Cooldown == "" {
+		alert.Cooldown = DefaultAlertCooldown
+	}
+	cooldown, err := time.ParseDuration(alert.Cooldown)
+	v.Check(err == nil && cooldown >= 0, "cooldown", "must be a duration such as '30m'")
+
+	return v.Err()
+}
+
+// AlertEvaluator checks alert rules against the latest prices and moves them
+// through their states: an armed alert fires once when its condition holds,
+// stays triggered until the condition clears, then cools down before it is
+// armed again.
+type AlertEvaluator struct {
+	Stores db.Stores
+}
+
+func NewAlertEvaluator(stores db.Stores) *AlertEvaluator {
+	return &AlertEvaluator{Stores: stores}
+}
+
+// Evaluate checks every alert against prices, a map of token ID to USD price.
+// Tokens missing from prices are read from the token store. Alerts without
+// the data they need are skipped, and failures of single alerts are logged.
+// The alerts that fired are returned.
+func (e *AlertEvaluator) Evaluate(ctx context.Context, prices map[string]float64, now time.Time) ([]models.AlertEvent, error) {
+	alerts, err := e.Stores.Alerts.ListAlerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := e.loadHoldings(ctx, alerts)
+	prices, err = e.completePrices(ctx, prices, alerts, holdings)
+	if err != nil {
+		return nil, err
+	}
+
+	fired := make([]models.AlertEvent, 0)
+	for _, alert := range alerts {
+		previous := alert.State
+
+		if alert.State == models.AlertCooldown {
+			if alert.CooldownUntil != nil && now.Before(*alert.CooldownUntil) {
+				continue
+			}
+			alert.State = models.AlertArmed
+			alert.CooldownUntil = nil
+		}
+
+		value, holds, err := e.check(ctx, alert, prices, holdings, now)
+		if err != nil {
+			log.Printf("❌ Failed to evaluate alert %s: %v", alert.ID, err)
+			continue
+		}
+
+		switch {
+		case holds == nil:
+			// Not enough data, keep the current state
+		case alert.State == models.AlertArmed && *holds:
+			event := alertEvent(alert, value, now)
+			if err := e.Stores.Alerts.SaveAlertEvent(ctx, event); err != nil {
+				log.Printf("❌ Failed to record alert %s: %v", alert.ID, err)
+				continue
+			}
+			fired = append(fired, event)
+
+			alert.State = models.AlertTriggered
+			alert.TriggeredAt = &now
+		case alert.State == models.AlertTriggered && !*holds:
+			cooldown, _ := time.ParseDuration(alert.Cooldown)
+			if cooldown > 0 {
+				until := now.Add(cooldown)
+				alert.State = models.AlertCooldown
+				alert.CooldownUntil = &until
+			} else {
+				alert.State = models.AlertArmed
+			}
+		}
+
+		if alert.State != previous {
+			if err := e.Stores.Alerts.UpdateAlertState(ctx, alert); err != nil {
+				log.Printf("❌ Failed to update alert %s: %v", alert.ID, err)
+			}
+		}
+	}
+
+	return fired, nil
+}
+
+// check returns the value an alert watches and whether its condition holds,
+// or a nil result when the value is not known
+func (e *AlertEvaluator) check(ctx context.Context, alert models.Alert, prices map[string]float64,
+	holdings map[string][]models.Portfolio, now time.Time) (float64, *bool, error) {
+	var value float64
+	var holds bool
+
+	switch alert.Type {
+	case models.AlertPriceAbove, models.AlertPriceBelow:
+		price, ok := prices[alert.TokenID]
+		if !ok {
+			return 0, nil, nil
+		}
+		value = price
+		if alert.Type == models.AlertPriceAbove {
+			holds = price >= alert.Threshold
+		} else {
+			holds = price <= alert.Threshold
+		}
+
+	case models.AlertPercentChange:
+		price, ok := prices[alert.TokenID]
+		if !ok {
+			return 0, nil, nil
+		}
+		window, _ := time.ParseDuration(alert.Window)
+		past, err := e.Stores.Prices.GetPointAt(ctx, alert.TokenID, now.Add(-window))
+		if errors.Is(err, db.ErrNotFound) || (err == nil && past.Price == 0) {
+			return 0, nil, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		value = percentChange(past.Price, price)
+		if alert.Threshold > 0 {
+			holds = value >= alert.Threshold
+		} else {
+			holds = value <= alert.Threshold
+		}
+
+	case models.AlertPortfolioBelow:
+		userHoldings := holdings[alert.UserID]
+		if len(userHoldings) == 0 {
+			return 0, nil, nil
+		}
+		// A partial value would look like a drop
+		valuation := ValuePortfolio(alert.UserID, userHoldings, prices)
+		if len(valuation.MissingPrices) > 0 {
+			return 0, nil, nil
+		}
+		value = valuation.TotalValue
+		holds = value <= alert.Threshold
+
+	default:
+		return 0, nil, nil
+	}
+
+	return value, &holds, nil
+}
+
+// loadHoldings fetches what users with portfolio alerts hold. Users whose
+// portfolio cannot be loaded are logged and their alerts skipped.
+func (e *AlertEvaluator) loadHoldings(ctx context.Context, alerts []models.Alert) map[string][]models.Portfolio {
+	holdings := make(map[string][]models.Portfolio)
+	for _, alert := range alerts {
+		if alert.Type != models.AlertPortfolioBelow {
+			continue
+		}
+		if _, ok := holdings[alert.UserID]; ok {
+			continue
+		}
+
+		userHoldings, err := CurrentHoldings(ctx, e.Stores, alert.UserID)
+		if err != nil {
+			log.Printf("❌ Failed to load portfolio of %s: %v", alert.UserID, err)
+		}
+		holdings[alert.UserID] = userHoldings
+	}
+	return holdings
+}
+
+// completePrices adds the stored prices of watched tokens missing from prices
+func (e *AlertEvaluator) completePrices(ctx context.Context, prices map[string]float64,
+	alerts []models.Alert, holdings map[string][]models.Portfolio) (map[string]float64, error) {
+	complete := make(map[string]float64, len(prices))
+	for id, price := range prices {
+		complete[id] = price
+	}
+
+	missing := make([]string, 0)
+	seen := make(map[string]bool)
+	addMissing := func(tokenID string) {
+		if _, ok := complete[tokenID]; !ok && tokenID != "" && !seen[tokenID] {
+			seen[tokenID] = true
+			missing = append(missing, tokenID)
+		}
+	}
+	for _, alert := range alerts {
+		addMissing(alert.TokenID)
+	}
+	for _, userHoldings := range holdings {
+		for _, holding := range userHoldings {
+			addMissing(holding.TokenID)
+		}
+	}
+
+	if len(missing) == 0 {
+		return complete, nil
+	}
+
+	stored, err := e.Stores.Tokens.GetTokenPrices(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for id, price := range stored {
+		complete[id] = price
+	}
+	return complete, nil
+}
+
+func alertEvent(alert models.Alert, value float64, now time.Time) models.AlertEvent {
+	var message string
+	switch alert.Type {
+	case models.AlertPriceAbove:
+		message = fmt.Sprintf("%s price %g is above %g", alert.TokenID, value, alert.Threshold)
+	case models.AlertPriceBelow:
+		message = fmt.Sprintf("%s price %g is below %g", alert.TokenID, value, alert.Threshold)
+	case models.AlertPercentChange:
+		message = fmt.Sprintf("%s price changed %.2f%% within %s", alert.TokenID, value, alert.Window)
+	case models.AlertPortfolioBelow:
+		message = fmt.Sprintf("portfolio value %.2f is below %g", value, alert.Threshold)
+	}
+
+	return models.AlertEvent{
+		AlertID:     alert.ID,
+		UserID:      alert.UserID,
+		Type:        alert.Type,
+		TokenID:     alert.TokenID,
+		Threshold:   alert.Threshold,
+		Value:       value,
+		Message:     message,
+		TriggeredAt: now,
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned by Login for an unknown user or a wrong password
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrInvalidToken is returned for a malformed, expired or wrongly typed token
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Token types, kept in the "typ" claim so a refresh token cannot be used as an access token
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// Password length bounds; bcrypt ignores everything past 72 bytes
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_-]{3,32}$`)
+
+// dummyHash is compared against when a username does not exist, so a login
+// for an unknown user takes as long as one with a wrong password
+var dummyHash = []byte("$2a$10$tRjN2WT1odalFQb7FojK3OrKPyobNIhvEiXbLpyayQHafzXu8Xxme")
+
+// Claims are the JWT claims of access and refresh tokens. The subject is the username.
+type Claims struct {
+	Role models.Role `json:"role"`
+	Type string      `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Principal is the authenticated caller of a request. Callers using an API
+// key are limited to its scopes; logged in sessions hold every scope.
+type Principal struct {
+	UserID   string      `json:"user_id"`
+	Role     models.Role `json:"role"`
+	APIKeyID string      `json:"api_key_id,omitempty"`
+	Scopes   []string    `json:"scopes,omitempty"`
+}
+
+// HasScope reports whether the caller may act within a scope
+func (p *Principal) HasScope(scope string) bool {
+	return p.APIKeyID == "" || slices.Contains(p.Scopes, scope)
+}
+
+// IsAdmin reports whether the caller may manage shared market data
+func (p *Principal) IsAdmin() bool {
+	return p.Role == models.RoleAdmin
+}
+
+// Authenticator registers users, checks their passwords and issues and
+// verifies the JWTs and API keys that authenticate API requests
+type Authenticator struct {
+	Stores     db.Stores
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewAuthenticator(stores db.Stores, cfg config.AuthConfig) (*Authenticator, error) {
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate JWT secret: %w", err)
+		}
+		log.Println("⚠️  No JWT secret configured, generated one; tokens will not survive a restart")
+	}
+
+	return &Authenticator{
+		Stores:     stores,
+		Secret:     secret,
+		AccessTTL:  cfg.AccessTTL,
+		RefreshTTL: cfg.RefreshTTL,
+	}, nil
+}
+
+// Register creates a regular user account
+func (a *Authenticator) Register(ctx context.Context, username, password string) (*models.User, error) {
+	return a.CreateUser(ctx, username, password, models.RoleUser)
+}
+
+// CreateUser validates the credentials and stores a user with a role. It
+// returns db.ErrConflict when the username is taken.
+func (a *Authenticator) CreateUser(ctx context.Context, username, password string, role models.Role) (*models.User, error) {
+	var v apierror.Validator
+	v.Check(usernamePattern.MatchString(username), "username", "must be 3-32 lowercase letters, digits, '_' or '-'")
+	v.Check(len(password) >= minPasswordLength && len(password) <= maxPasswordLength,
+		"password", "must be %d-%d characters", minPasswordLength, maxPasswordLength)
+	v.Check(role == models.RoleUser || role == models.RoleAdmin, "role", "unknown role '%s'", role)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := models.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		CreatedAt:    time.Now(),
+	}
+	if err := a.Stores.Users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Login checks a user's password and issues a token pair
+func (a *Authenticator) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	user, err := a.Stores.Users.GetUser(ctx, username)
+	if errors.Is(err, db.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.issue(user)
+}
+
+// Refresh exchanges a refresh token for a new pair. The user is read again,
+// so role changes apply and deleted users cannot refresh.
+func (a *Authenticator) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := a.parse(refreshToken, TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.Stores.Users.GetUser(ctx, claims.Subject)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.issue(user)
+}
+
+// Authenticate verifies an access token and returns its caller
+func (a *Authenticator) Authenticate(accessToken string) (*Principal, error) {
+	claims, err := a.parse(accessToken, TokenAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{UserID: claims.Subject, Role: claims.Role}, nil
+}
+
+func (a *Authenticator) issue(user *models.User) (*TokenPair, error) {
+	access, err := a.sign(user, TokenAccess, a.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := a.sign(user, TokenRefresh, a.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(a.AccessTTL / time.Second),
+	}, nil
+}
+
+func (a *Authenticator) sign(user *models.User, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Role: user.Role,
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.Secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+func (a *Authenticator) parse(token, tokenType string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return a.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != tokenType || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
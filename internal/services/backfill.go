@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrBackfillRunning = errors.New("backfill already running")
+
+// Backfiller loads historical prices from the price provider into the price
+// history. Ranges are fetched in windows of Chunk and the progress is stored
+// after each window, so a failed backfill resumes instead of starting over.
+// Points are upserted on their timestamp, which makes repeated runs harmless.
+type Backfiller struct {
+	Stores   db.Stores
+	Provider PriceProvider
+	Chunk    time.Duration
+	MaxDays  int
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewBackfiller(stores db.Stores, provider PriceProvider, cfg config.BackfillConfig) *Backfiller {
+	return &Backfiller{
+		Stores:   stores,
+		Provider: provider,
+		Chunk:    cfg.Chunk,
+		MaxDays:  cfg.MaxDays,
+		running:  make(map[string]bool),
+	}
+}
+
+// Start begins backfilling the last days of a token in the background and
+// returns the initial state
+func (b *Backfiller) Start(ctx context.Context, tokenID string, days int) (*models.BackfillState, error) {
+	state, err := b.begin(ctx, tokenID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	go b.run(ctx, *state)
+	return state, nil
+}
+
+// Backfill backfills the last days of a token and returns the final state
+func (b *Backfiller) Backfill(ctx context.Context, tokenID string, days int) (*models.BackfillState, error) {
+	state, err := b.begin(ctx, tokenID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	final := b.run(ctx, *state)
+	if final.Status == models.BackfillFailed {
+		return &final, fmt.Errorf("backfill of %s failed: %s", tokenID, final.Error)
+	}
+	return &final, nil
+}
+
+// begin claims the token and plans the backfill, resuming stored progress
+func (b *Backfiller) begin(ctx context.Context, tokenID string, days int) (*models.BackfillState, error) {
+	if days < 1 || days > b.MaxDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", b.MaxDays)
+	}
+
+	b.mu.Lock()
+	if b.running[tokenID] {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("%w for %s", ErrBackfillRunning, tokenID)
+	}
+	b.running[tokenID] = true
+	b.mu.Unlock()
+
+	to := time.Now().UTC().Truncate(time.Minute)
+	from := to.AddDate(0, 0, -days)
+	state := models.BackfillState{
+		TokenID:   tokenID,
+		From:      from,
+		To:        to,
+		ResumeAt:  from,
+		Status:    models.BackfillRunning,
+		UpdatedAt: time.Now(),
+	}
+
+	// Skip the windows a previous backfill of the same or a wider range stored
+	prev, err := b.Stores.Backfills.GetBackfill(ctx, tokenID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		b.release(tokenID)
+		return nil, err
+	}
+	if err == nil && !prev.From.After(from) && prev.ResumeAt.After(from) {
+		state.ResumeAt = prev.ResumeAt
+		state.Points = prev.Points
+	}
+
+	if err := b.Stores.Backfills.SaveBackfill(ctx, state); err != nil {
+		b.release(tokenID)
+		return nil, err
+	}
+	return &state, nil
+}
+
+// run fetches and stores the remaining windows, recording progress after each
+func (b *Backfiller) run(ctx context.Context, state models.BackfillState) models.BackfillState {
+	defer b.release(state.TokenID)
+
+	log.Printf("📈 Backfilling %s from %s to %s", state.TokenID,
+		state.ResumeAt.Format(time.RFC3339), state.To.Format(time.RFC3339))
+
+	for state.ResumeAt.Before(state.To) {
+		end := state.ResumeAt.Add(b.Chunk)
+		if end.After(state.To) {
+			end = state.To
+		}
+
+		points, err := b.Provider.FetchHistory(ctx, state.TokenID, state.ResumeAt, end)
+		if err == nil {
+			err = b.Stores.Prices.SavePrices(ctx, points)
+		}
+		if err == nil {
+			err = RebuildCandles(ctx, b.Stores, state.TokenID, state.ResumeAt, end)
+		}
+		if err != nil {
+			log.Printf("❌ Backfill of %s failed: %v", state.TokenID, err)
+			state.Status = models.BackfillFailed
+			state.Error = err.Error()
+			b.save(ctx, state)
+			return state
+		}
+
+		state.Points += len(points)
+		state.ResumeAt = end
+		b.save(ctx, state)
+	}
+
+	state.Status = models.BackfillCompleted
+	b.save(ctx, state)
+
+	log.Printf("✅ Backfilled %d prices for %s", state.Points, state.TokenID)
+	return state
+}
+
+func (b *Backfiller) save(ctx context.Context, state models.BackfillState) {
+	state.UpdatedAt = time.Now()
+	if err := b.Stores.Backfills.SaveBackfill(ctx, state); err != nil {
+		log.Printf("❌ Failed to save backfill state of %s: %v", state.TokenID, err)
+	}
+}
+
+func (b *Backfiller) release(tokenID string) {
+	b.mu.Lock()
+	delete(b.running, tokenID)
+	b.mu.Unlock()
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BinanceClient reads spot tickers from the Binance REST API.
+// Tokens are mapped to <SYMBOL><QUOTE> pairs such as BTCUSDT; Binance has
+// no market cap data so it is left at zero.
+type BinanceClient struct {
+	BaseURL    string
+	QuoteAsset string
+	Symbols    map[string]string
+	HTTPClient *http.Client
+	requester  *requester
+}
+
+func NewBinanceClient(cfg config.ExchangeConfig, symbols map[string]string) *BinanceClient {
+	httpClient := &http.Client{
+		Timeout: cfg.Timeout,
+	}
+	return &BinanceClient{
+		BaseURL:    cfg.BaseURL,
+		QuoteAsset: cfg.QuoteAsset,
+		Symbols:    symbols,
+		HTTPClient: httpClient,
+		requester:  &requester{Provider: "binance", Client: httpClient},
+	}
+}
+
+// Binance 24h ticker response structure
+type binanceTicker struct {
+	Symbol      string `json:"symbol"`
+	LastPrice   string `json:"lastPrice"`
+	QuoteVolume string `json:"quoteVolume"`
+}
+
+func (c *BinanceClient) Name() string {
+	return "binance"
+}
+
+// Fetch the configured tokens ordered by 24h quote volume
+func (c *BinanceClient) FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error) {
+	ids := make([]string, 0, len(c.Symbols))
+	for id := range c.Symbols {
+		ids = append(ids, id)
+	}
+
+	tokens, err := c.FetchTokens(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Volume24h > tokens[j].Volume24h })
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+	return tokens, nil
+}
+
+// Fetch tokens by ID, skipping IDs without a configured symbol
+func (c *BinanceClient) FetchTokens(ctx context.Context, ids []string) ([]models.Token, error) {
+	pairs := make([]string, 0, len(ids))
+	idsByPair := make(map[string]string, len(ids))
+	for _, id := range ids {
+		pair, ok := c.pair(id)
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, pair)
+		idsByPair[pair] = id
+	}
+
+	if len(pairs) == 0 {
+		return []models.Token{}, nil
+	}
+	sort.Strings(pairs)
+
+	symbolsJSON, _ := json.Marshal(pairs)
+	endpoint := fmt.Sprintf("%s/api/v3/ticker/24hr?symbols=%s", c.BaseURL, url.QueryEscape(string(symbolsJSON)))
+
+	var tickers []binanceTicker
+	if err := c.requester.getJSON(ctx, endpoint, &tickers); err != nil {
+		return nil, fmt.Errorf("failed to fetch tickers: %w", err)
+	}
+
+	tokens := make([]models.Token, 0, len(tickers))
+	for _, ticker := range tickers {
+		id, ok := idsByPair[ticker.Symbol]
+		if !ok {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(ticker.LastPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", ticker.Symbol, err)
+		}
+		volume, _ := strconv.ParseFloat(ticker.QuoteVolume, 64)
+
+		symbol := c.Symbols[id]
+		tokens = append(tokens, models.Token{
+			ID:           id,
+			Symbol:       strings.ToLower(symbol),
+			Name:         symbol,
+			CurrentPrice: price,
+			Volume24h:    volume,
+			UpdatedAt:    time.Now(),
+			Sources:      []string{"binance"},
+		})
+	}
+
+	return tokens, nil
+}
+
+// Fetch historical close prices from klines, paging through the range. Each
+// point is stamped with its kline's close time, or now for the open kline.
+func (c *BinanceClient) FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error) {
+	pair, ok := c.pair(id)
+	if !ok {
+		return nil, fmt.Errorf("no Binance symbol configured for %s", id)
+	}
+
+	interval := "1d"
+	switch span := to.Sub(from); {
+	case span <= 24*time.Hour:
+		interval = "5m"
+	case span <= 90*24*time.Hour:
+		interval = "1h"
+	}
+
+	points := make([]models.PriceHistory, 0)
+	start := from.UnixMilli()
+	for start < to.UnixMilli() {
+		endpoint := fmt.Sprintf("%s/api/v3/klines?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=1000",
+			c.BaseURL, pair, interval, start, to.UnixMilli())
+
+		// Each kline is [openTime, open, high, low, close, volume, closeTime, ...]
+		var klines [][]json.RawMessage
+		if err := c.requester.getJSON(ctx, endpoint, &klines); err != nil {
+			return nil, fmt.Errorf("failed to fetch klines: %w", err)
+		}
+		if len(klines) == 0 {
+			break
+		}
+
+		now := time.Now()
+		for _, k := range klines {
+			var closeTime int64
+			var closePrice string
+			if len(k) < 7 || json.Unmarshal(k[4], &closePrice) != nil || json.Unmarshal(k[6], &closeTime) != nil {
+				return nil, fmt.Errorf("unexpected kline format")
+			}
+			price, err := strconv.ParseFloat(closePrice, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid kline price: %w", err)
+			}
+
+			points = append(points, models.PriceHistory{
+				TokenID:   id,
+				Price:     price,
+				Timestamp: minTime(time.UnixMilli(closeTime), now).UTC(),
+			})
+			start = closeTime + 1
+		}
+	}
+
+	return points, nil
+}
+
+// pair returns the trading pair of a token; the quote asset itself has no pair
+func (c *BinanceClient) pair(id string) (string, bool) {
+	symbol, ok := c.Symbols[id]
+	if !ok || strings.EqualFold(symbol, c.QuoteAsset) {
+		return "", false
+	}
+	return strings.ToUpper(symbol) + strings.ToUpper(c.QuoteAsset), true
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CandleIntervals are the rolled up candle granularities
+var CandleIntervals = []string{"1m", "5m", "1h", "1d"}
+
+var candleDurations = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// ParseInterval returns the duration of a candle interval name
+func ParseInterval(interval string) (time.Duration, error) {
+	d, ok := candleDurations[interval]
+	if !ok {
+		return 0, fmt.Errorf("invalid interval '%s' (use 1m, 5m, 1h or 1d)", interval)
+	}
+	return d, nil
+}
+
+// BuildCandles buckets points, oldest first, into candles of the given
+// duration. Buckets are aligned to UTC and buckets without points are skipped.
+func BuildCandles(points []models.PriceHistory, d time.Duration) []models.Candle {
+	candles := make([]models.Candle, 0)
+	for _, point := range points {
+		start := point.Timestamp.UTC().Truncate(d)
+		if n := len(candles); n > 0 && candles[n-1].Start.Equal(start) {
+			addToCandle(&candles[n-1], point)
+			continue
+		}
+		candles = append(candles, newCandle(start, point))
+	}
+	return candles
+}
+
+func newCandle(start time.Time, point models.PriceHistory) models.Candle {
+	return models.Candle{
+		Start:     start,
+		Open:      point.Price,
+		High:      point.Price,
+		Low:       point.Price,
+		Close:     point.Price,
+		Count:     1,
+		OpenTime:  point.Timestamp,
+		CloseTime: point.Timestamp,
+	}
+}
+
+// addToCandle merges a point into a candle in any order
+func addToCandle(c *models.Candle, point models.PriceHistory) {
+	c.High = max(c.High, point.Price)
+	c.Low = min(c.Low, point.Price)
+	c.Count++
+	if point.Timestamp.Before(c.OpenTime) {
+		c.Open = point.Price
+		c.OpenTime = point.Timestamp
+	}
+	if !point.Timestamp.Before(c.CloseTime) {
+		c.Close = point.Price
+		c.CloseTime = point.Timestamp
+	}
+}
+
+// RollupPoint merges a new price into the stored candle of each interval.
+// Each merge reads and rewrites the candle without a guard, so it assumes a
+// single writer: the price worker of one API instance. A second instance
+// running the worker would count ticks twice or lose some of them.
+func RollupPoint(ctx context.Context, store db.CandleStore, point models.PriceHistory) error {
+	for _, interval := range CandleIntervals {
+		start := point.Timestamp.UTC().Truncate(candleDurations[interval])
+
+		candle, err := store.GetCandle(ctx, point.TokenID, interval, start)
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			c := newCandle(start, point)
+			candle = &c
+		case err != nil:
+			return err
+		default:
+			addToCandle(candle, point)
+		}
+
+		if err := store.SaveCandles(ctx, point.TokenID, interval, []models.Candle{*candle}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildCandles recomputes every interval's candles covering from..to from
+// the raw price history, replacing what was stored. Only buckets holding raw
+// ticks are written, so candles whose ticks have expired are kept as they are.
+func RebuildCandles(ctx context.Context, stores db.Stores, tokenID string, from, to time.Time) error {
+	for _, interval := range CandleIntervals {
+		d := candleDurations[interval]
+		start := from.UTC().Truncate(d)
+		end := to.UTC().Truncate(d).Add(d - time.Nanosecond)
+
+		points, err := stores.Prices.GetRawPriceRange(ctx, tokenID, start, end)
+		if err != nil {
+			return err
+		}
+
+		if err := stores.Candles.SaveCandles(ctx, tokenID, interval, BuildCandles(points, d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
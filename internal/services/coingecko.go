@@ -1,24 +1,51 @@
 package services
 
 import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
 	"crypto-portfolio-tracker/internal/models"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// CoinGeckoClient talks to the CoinGecko API. All requests of a client share
+// one rate limiter, so the worker and the sync handler never exceed the plan's
+// limit together; rate limited and failed requests are retried with backoff.
 type CoinGeckoClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	Limiter    *RateLimiter
+	requester  *requester
 }
 
-func NewCoinGeckoClient() *CoinGeckoClient {
+func NewCoinGeckoClient(cfg config.CoinGeckoConfig) *CoinGeckoClient {
+	httpClient := &http.Client{
+		Timeout: cfg.Timeout,
+	}
+	limiter := NewRateLimiter(cfg.RequestsPerMinute, cfg.Burst)
+
+	header := http.Header{}
+	if cfg.APIKey != "" {
+		header.Set("x-cg-demo-api-key", cfg.APIKey)
+	}
+
 	return &CoinGeckoClient{
-		BaseURL: "https://api.coingecko.com/api/v3",
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
+		BaseURL:    cfg.BaseURL,
+		HTTPClient: httpClient,
+		Limiter:    limiter,
+		requester: &requester{
+			Provider: "coingecko",
+			Client:   httpClient,
+			Limiter:  limiter,
+			Retry: RetryPolicy{
+				MaxRetries: cfg.MaxRetries,
+				BaseDelay:  cfg.RetryBaseDelay,
+				MaxDelay:   cfg.RetryMaxDelay,
+			},
+			Header: header,
 		},
 	}
 }
@@ -34,81 +61,124 @@ type CoinGeckoToken struct {
 	PriceChange24h float64 `json:"price_change_24h"`
 }
 
-// Fetch top tokens by market cap
-func (c *CoinGeckoClient) FetchTopTokens(limit int) ([]models.Token, error) {
-	url := fmt.Sprintf("%s/coins/markets?vs_currency=usd&order=market_cap_desc&per_page=%d&page=1&sparkline=false",
-		c.BaseURL, limit)
+func (c *CoinGeckoClient) Name() string {
+	return "coingecko"
+}
 
-	resp, err := c.HTTPClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tokens: %w", err)
-	}
-	defer resp.Body.Close()
+// CoinGecko serves at most 250 markets per page; ids lookups are batched
+// below that to keep request URLs short
+const (
+	coinGeckoPageSize = 250
+	coinGeckoIDBatch  = 100
+)
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s (status %d)", string(body), resp.StatusCode)
+// Fetch top tokens by market cap, requesting as many pages as limit needs
+func (c *CoinGeckoClient) FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error) {
+	if limit <= 0 {
+		return []models.Token{}, nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	perPage := min(limit, coinGeckoPageSize)
+	tokens := make([]models.Token, 0, limit)
+
+	for page := 1; len(tokens) < limit; page++ {
+		endpoint := fmt.Sprintf("%s/coins/markets?vs_currency=usd&order=market_cap_desc&per_page=%d&page=%d&sparkline=false",
+			c.BaseURL, perPage, page)
+
+		var cgTokens []CoinGeckoToken
+		if err := c.requester.getJSON(ctx, endpoint, &cgTokens); err != nil {
+			return nil, fmt.Errorf("failed to fetch tokens page %d: %w", page, err)
+		}
+
+		tokens = append(tokens, convertCoinGeckoTokens(cgTokens)...)
+
+		// A short page means there are no more markets
+		if len(cgTokens) < perPage {
+			break
+		}
 	}
 
-	var cgTokens []CoinGeckoToken
-	if err := json.Unmarshal(body, &cgTokens); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
 	}
+	return tokens, nil
+}
 
-	// Convert to our Token model
-	tokens := make([]models.Token, 0, len(cgTokens))
-	for _, cg := range cgTokens {
-		tokens = append(tokens, models.Token{
-			ID:           cg.ID,
-			Symbol:       cg.Symbol,
-			Name:         cg.Name,
-			CurrentPrice: cg.CurrentPrice,
-			MarketCap:    cg.MarketCap,
-			Volume24h:    cg.TotalVolume,
-			UpdatedAt:    time.Now(),
-		})
+// Fetch tokens by ID in batches, skipping unknown IDs
+func (c *CoinGeckoClient) FetchTokens(ctx context.Context, ids []string) ([]models.Token, error) {
+	tokens := make([]models.Token, 0, len(ids))
+
+	for start := 0; start < len(ids); start += coinGeckoIDBatch {
+		batch := ids[start:min(start+coinGeckoIDBatch, len(ids))]
+
+		endpoint := fmt.Sprintf("%s/coins/markets?vs_currency=usd&ids=%s&per_page=%d&sparkline=false",
+			c.BaseURL, url.QueryEscape(strings.Join(batch, ",")), coinGeckoPageSize)
+
+		var cgTokens []CoinGeckoToken
+		if err := c.requester.getJSON(ctx, endpoint, &cgTokens); err != nil {
+			return nil, fmt.Errorf("failed to fetch tokens: %w", err)
+		}
+
+		tokens = append(tokens, convertCoinGeckoTokens(cgTokens)...)
 	}
 
 	return tokens, nil
 }
 
 // Fetch single token by ID
-func (c *CoinGeckoClient) FetchToken(tokenID string) (*models.Token, error) {
-	url := fmt.Sprintf("%s/coins/markets?vs_currency=usd&ids=%s", c.BaseURL, tokenID)
-
-	resp, err := c.HTTPClient.Get(url)
+func (c *CoinGeckoClient) FetchToken(ctx context.Context, tokenID string) (*models.Token, error) {
+	tokens, err := c.FetchTokens(ctx, []string{tokenID})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch token: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token %s: %w", tokenID, ErrNotFound)
 	}
 
-	var cgTokens []CoinGeckoToken
-	if err := json.Unmarshal(body, &cgTokens); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	return &tokens[0], nil
+}
+
+// Fetch historical prices from the market_chart/range endpoint.
+// CoinGecko picks the granularity from the range: minutely up to a day,
+// hourly up to 90 days and daily beyond.
+func (c *CoinGeckoClient) FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error) {
+	endpoint := fmt.Sprintf("%s/coins/%s/market_chart/range?vs_currency=usd&from=%d&to=%d",
+		c.BaseURL, url.PathEscape(id), from.Unix(), to.Unix())
+
+	var chart struct {
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err := c.requester.getJSON(ctx, endpoint, &chart); err != nil {
+		return nil, fmt.Errorf("failed to fetch history: %w", err)
 	}
 
-	if len(cgTokens) == 0 {
-		return nil, fmt.Errorf("token not found: %s", tokenID)
+	points := make([]models.PriceHistory, 0, len(chart.Prices))
+	for _, p := range chart.Prices {
+		points = append(points, models.PriceHistory{
+			TokenID:   id,
+			Price:     p[1],
+			Timestamp: time.UnixMilli(int64(p[0])).UTC(),
+		})
 	}
 
-	cg := cgTokens[0]
-	return &models.Token{
-		ID:           cg.ID,
-		Symbol:       cg.Symbol,
-		Name:         cg.Name,
-		CurrentPrice: cg.CurrentPrice,
-		MarketCap:    cg.MarketCap,
-		Volume24h:    cg.TotalVolume,
-		UpdatedAt:    time.Now(),
-	}, nil
+	return points, nil
+}
+
+// Convert to our Token model
+func convertCoinGeckoTokens(cgTokens []CoinGeckoToken) []models.Token {
+	tokens := make([]models.Token, 0, len(cgTokens))
+	for _, cg := range cgTokens {
+		tokens = append(tokens, models.Token{
+			ID:           cg.ID,
+			Symbol:       cg.Symbol,
+			Name:         cg.Name,
+			CurrentPrice: cg.CurrentPrice,
+			MarketCap:    cg.MarketCap,
+			Volume24h:    cg.TotalVolume,
+			UpdatedAt:    time.Now(),
+			Sources:      []string{"coingecko"},
+		})
+	}
+	return tokens
 }
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BaseCurrency is the currency every provider price is stored in
+const BaseCurrency = "usd"
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// ExchangeRateSource supplies exchange rates in units of each currency per US dollar
+type ExchangeRateSource interface {
+	FetchExchangeRates(ctx context.Context) (map[string]float64, error)
+}
+
+// NewExchangeRateSource returns the CoinGecko client among the configured
+// providers, so exchange rate requests share its rate limit, or a new one
+func NewExchangeRateSource(provider PriceProvider, cfg config.CoinGeckoConfig) ExchangeRateSource {
+	if client := findCoinGecko(provider); client != nil {
+		return client
+	}
+	return NewCoinGeckoClient(cfg)
+}
+
+func findCoinGecko(provider PriceProvider) *CoinGeckoClient {
+	switch p := provider.(type) {
+	case *CoinGeckoClient:
+		return p
+	case *FallbackProvider:
+		for _, inner := range p.Providers {
+			if client := findCoinGecko(inner); client != nil {
+				return client
+			}
+		}
+	case *AggregatingProvider:
+		for _, inner := range p.Providers {
+			if client := findCoinGecko(inner); client != nil {
+				return client
+			}
+		}
+	}
+	return nil
+}
+
+// Fetch exchange rates from the exchange_rates endpoint, which quotes every
+// currency against bitcoin, and rebase them on the US dollar
+func (c *CoinGeckoClient) FetchExchangeRates(ctx context.Context) (map[string]float64, error) {
+	endpoint := fmt.Sprintf("%s/exchange_rates", c.BaseURL)
+
+	var response struct {
+		Rates map[string]struct {
+			Value float64 `json:"value"`
+		} `json:"rates"`
+	}
+	if err := c.requester.getJSON(ctx, endpoint, &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+
+	usd, ok := response.Rates[BaseCurrency]
+	if !ok || usd.Value <= 0 {
+		return nil, fmt.Errorf("exchange rates lack a %s rate", BaseCurrency)
+	}
+
+	rates := make(map[string]float64, len(response.Rates))
+	for currency, rate := range response.Rates {
+		rates[currency] = rate.Value / usd.Value
+	}
+	return rates, nil
+}
+
+// Quotes converts a USD price into each of the given currencies, skipping
+// currencies without a known rate
+func Quotes(usdPrice float64, rates map[string]float64, currencies []string) map[string]float64 {
+	quotes := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		if currency == BaseCurrency {
+			quotes[currency] = usdPrice
+			continue
+		}
+		if rate, ok := rates[currency]; ok {
+			quotes[currency] = usdPrice * rate
+		}
+	}
+	return quotes
+}
+
+// Converter turns USD amounts into a single quote currency
+type Converter struct {
+	Currency string
+	Rate     float64
+}
+
+// NewConverter looks up the rate of currency, which defaults to USD
+func NewConverter(currency string, rates map[string]float64) (Converter, error) {
+	currency = strings.ToLower(currency)
+	if currency == "" || currency == BaseCurrency {
+		return Converter{Currency: BaseCurrency, Rate: 1}, nil
+	}
+
+	rate, ok := rates[currency]
+	if !ok || rate <= 0 {
+		return Converter{}, fmt.Errorf("%w '%s'", ErrUnsupportedCurrency, currency)
+	}
+	return Converter{Currency: currency, Rate: rate}, nil
+}
+
+// IsBase reports whether the converter leaves amounts in USD
+func (c Converter) IsBase() bool {
+	return c.Currency == BaseCurrency
+}
+
+// Convert converts a USD amount at the current rate
+func (c Converter) Convert(usd float64) float64 {
+	return usd * c.Rate
+}
+
+// Price prefers the quote stored with a price and converts the USD price otherwise
+func (c Converter) Price(usdPrice float64, quotes map[string]float64) float64 {
+	if quote, ok := quotes[c.Currency]; ok {
+		return quote
+	}
+	return c.Convert(usdPrice)
+}
+
+// Token returns a copy of token with its prices in the converter's currency
+func (c Converter) Token(token models.Token) models.Token {
+	if c.IsBase() {
+		return token
+	}
+
+	token.CurrentPrice = c.Price(token.CurrentPrice, token.Quotes)
+	token.MarketCap = c.Convert(token.MarketCap)
+	token.Volume24h = c.Convert(token.Volume24h)
+	token.Currency = c.Currency
+	return token
+}
+
+// PricePoint returns a copy of point with its price in the converter's currency
+func (c Converter) PricePoint(point models.PriceHistory) models.PriceHistory {
+	point.Price = c.Price(point.Price, point.Quotes)
+	return point
+}
+
+// Valuation converts every amount of a USD valuation at the current rate
+func (c Converter) Valuation(valuation models.PortfolioValuation) models.PortfolioValuation {
+	if c.IsBase() {
+		return valuation
+	}
+
+	holdings := make([]models.HoldingValuation, len(valuation.Holdings))
+	for i, hv := range valuation.Holdings {
+		hv.BuyPrice = c.Convert(hv.BuyPrice)
+		hv.CurrentPrice = c.Convert(hv.CurrentPrice)
+		hv.MarketValue = c.Convert(hv.MarketValue)
+		hv.CostBasis = c.Convert(hv.CostBasis)
+		hv.UnrealizedPnL = c.Convert(hv.UnrealizedPnL)
+		holdings[i] = hv
+	}
+
+	valuation.Holdings = holdings
+	valuation.TotalValue = c.Convert(valuation.TotalValue)
+	valuation.TotalCostBasis = c.Convert(valuation.TotalCostBasis)
+	valuation.UnrealizedPnL = c.Convert(valuation.UnrealizedPnL)
+	valuation.Currency = c.Currency
+	return valuation
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"crypto-portfolio-tracker/internal/models"
+	"sort"
+	"time"
+)
+
+// AmountsAsOf returns the amount of each token held at every timestamp.
+// The ledger is used when present; otherwise holdings count from their buy date.
+func AmountsAsOf(timestamps []time.Time, transactions []models.Transaction, holdings []models.Portfolio) []map[string]float64 {
+	result := make([]map[string]float64, len(timestamps))
+
+	if len(transactions) == 0 {
+		for i, t := range timestamps {
+			amounts := make(map[string]float64)
+			for _, holding := range holdings {
+				if !holding.BuyDate.After(t) {
+					amounts[holding.TokenID] += holding.Amount
+				}
+			}
+			result[i] = amounts
+		}
+		return result
+	}
+
+	// Walk the sorted ledger once, snapshotting the running balances at each step
+	amounts := make(map[string]float64)
+	next := 0
+	for i, t := range timestamps {
+		for next < len(transactions) && !transactions[next].Timestamp.After(t) {
+			tx := transactions[next]
+			switch tx.Type {
+			case models.TransactionBuy, models.TransactionDeposit:
+				amounts[tx.TokenID] += tx.Amount
+			case models.TransactionSell, models.TransactionWithdraw, models.TransactionFee:
+				amounts[tx.TokenID] -= tx.Amount
+			}
+			next++
+		}
+
+		snapshot := make(map[string]float64, len(amounts))
+		for tokenID, amount := range amounts {
+			if amount > balanceEpsilon {
+				snapshot[tokenID] = amount
+			}
+		}
+		result[i] = snapshot
+	}
+
+	return result
+}
+
+// NearestPrice returns the price of the point closest to t from points sorted oldest first
+func NearestPrice(points []models.PriceHistory, t time.Time) (float64, bool) {
+	if len(points) == 0 {
+		return 0, false
+	}
+
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(t)
+	})
+	if i == 0 {
+		return points[0].Price, true
+	}
+	if i == len(points) {
+		return points[len(points)-1].Price, true
+	}
+
+	before, after := points[i-1], points[i]
+	if t.Sub(before.Timestamp) <= after.Timestamp.Sub(t) {
+		return before.Price, true
+	}
+	return after.Price, true
+}
+
+// ValueSeries values the held amounts at each timestamp with the nearest stored price
+func ValueSeries(timestamps []time.Time, amounts []map[string]float64, prices map[string][]models.PriceHistory) []models.PortfolioValuePoint {
+	series := make([]models.PortfolioValuePoint, 0, len(timestamps))
+
+	for i, t := range timestamps {
+		point := models.PortfolioValuePoint{Timestamp: t}
+		for tokenID, amount := range amounts[i] {
+			price, ok := NearestPrice(prices[tokenID], t)
+			if !ok {
+				point.MissingPrices = append(point.MissingPrices, tokenID)
+				continue
+			}
+			point.Value += amount * price
+		}
+		sort.Strings(point.MissingPrices)
+		series = append(series, point)
+	}
+
+	return series
+}
+
+// Steps returns the timestamps from from to to (inclusive) spaced by step
+func Steps(from, to time.Time, step time.Duration) []time.Time {
+	steps := make([]time.Time, 0)
+	for t := from; !t.After(to); t = t.Add(step) {
+		steps = append(steps, t)
+	}
+	return steps
+}
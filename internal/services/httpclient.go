@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Provider errors, matched with errors.Is against an *APIError
+var (
+	ErrRateLimited     = errors.New("rate limited")
+	ErrNotFound        = errors.New("not found")
+	ErrServerError     = errors.New("server error")
+	ErrRequestRejected = errors.New("request rejected")
+)
+
+// errPermanent marks failures that retrying cannot fix, such as malformed responses
+var errPermanent = errors.New("permanent failure")
+
+// APIError describes a non-200 response of a price provider
+type APIError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error: %v (status %d): %s", e.Provider, e.kind, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       string(body),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.kind = ErrRateLimited
+	case resp.StatusCode == http.StatusNotFound:
+		apiErr.kind = ErrNotFound
+	case resp.StatusCode >= 500:
+		apiErr.kind = ErrServerError
+	default:
+		apiErr.kind = ErrRequestRejected
+	}
+
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// RetryPolicy controls retries of rate limited, failed or unreachable requests.
+// Delays grow exponentially from BaseDelay up to MaxDelay with random jitter,
+// unless the provider sends Retry-After.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	backoff := p.BaseDelay << attempt
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	// Equal jitter: at least half the backoff so retries still spread out
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// requester sends GET requests for a provider, honoring an optional shared
+// rate limiter and retry policy
+type requester struct {
+	Provider string
+	Client   *http.Client
+	Limiter  *RateLimiter
+	Retry    RetryPolicy
+	Header   http.Header
+}
+
+// getJSON fetches url and decodes a successful JSON response into out
+func (r *requester) getJSON(ctx context.Context, url string, out interface{}) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= r.Retry.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := r.Retry.delay(attempt - 1)
+			var apiErr *APIError
+			if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+				// Waiting longer than the policy allows is left to the caller
+				if apiErr.RetryAfter > r.Retry.MaxDelay {
+					return lastErr
+				}
+				wait = apiErr.RetryAfter
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return lastErr
+			}
+		}
+
+		if r.Limiter != nil {
+			if err := r.Limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		lastErr = r.do(ctx, url, out)
+		if lastErr == nil || !retryable(ctx, lastErr) {
+			return lastErr
+		}
+
+		var apiErr *APIError
+		if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 && r.Limiter != nil {
+			r.Limiter.PauseFor(apiErr.RetryAfter)
+		}
+	}
+
+	return lastErr
+}
+
+func (r *requester) do(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: failed to build request: %v", errPermanent, err)
+	}
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return newAPIError(r.Provider, resp, body)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: failed to parse JSON: %v", errPermanent, err)
+	}
+
+	return nil
+}
+
+// retryable reports whether a failed request may succeed when sent again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServerError) {
+		return true
+	}
+	// Transport failures (timeouts, resets) carry no APIError
+	var apiErr *APIError
+	return !errors.As(err, &apiErr) && !errors.Is(err, errPermanent)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
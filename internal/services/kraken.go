@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// krakenAssets maps common tickers to the names Kraken uses for them
+var krakenAssets = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// KrakenClient reads spot tickers from the Kraken public REST API.
+// Kraken has no market cap data so it is left at zero.
+type KrakenClient struct {
+	BaseURL    string
+	QuoteAsset string
+	Symbols    map[string]string
+	HTTPClient *http.Client
+	requester  *requester
+}
+
+func NewKrakenClient(cfg config.ExchangeConfig, symbols map[string]string) *KrakenClient {
+	httpClient := &http.Client{
+		Timeout: cfg.Timeout,
+	}
+	return &KrakenClient{
+		BaseURL:    cfg.BaseURL,
+		QuoteAsset: cfg.QuoteAsset,
+		Symbols:    symbols,
+		HTTPClient: httpClient,
+		requester:  &requester{Provider: "kraken", Client: httpClient},
+	}
+}
+
+// Kraken wraps every response in an error list and a result object
+type krakenResponse struct {
+	Error  []string                   `json:"error"`
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+// Kraken ticker structure: c is [last price, lot volume], v is [today, last 24h]
+type krakenTicker struct {
+	C []string `json:"c"`
+	V []string `json:"v"`
+}
+
+func (c *KrakenClient) Name() string {
+	return "kraken"
+}
+
+// Fetch the configured tokens ordered by 24h volume
+func (c *KrakenClient) FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error) {
+	ids := make([]string, 0, len(c.Symbols))
+	for id := range c.Symbols {
+		ids = append(ids, id)
+	}
+
+	tokens, err := c.FetchTokens(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Volume24h > tokens[j].Volume24h })
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+	return tokens, nil
+}
+
+// Fetch tokens by ID, one pair per request since Kraken renames pairs in its response
+func (c *KrakenClient) FetchTokens(ctx context.Context, ids []string) ([]models.Token, error) {
+	tokens := make([]models.Token, 0, len(ids))
+	for _, id := range ids {
+		pair, ok := c.pair(id)
+		if !ok {
+			continue
+		}
+
+		var ticker krakenTicker
+		if err := c.get(ctx, fmt.Sprintf("%s/0/public/Ticker?pair=%s", c.BaseURL, pair), &ticker); err != nil {
+			return nil, fmt.Errorf("failed to fetch ticker %s: %w", pair, err)
+		}
+		if len(ticker.C) == 0 || len(ticker.V) < 2 {
+			return nil, fmt.Errorf("unexpected ticker format for %s", pair)
+		}
+
+		price, err := strconv.ParseFloat(ticker.C[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", pair, err)
+		}
+		baseVolume, _ := strconv.ParseFloat(ticker.V[1], 64)
+
+		symbol := c.Symbols[id]
+		tokens = append(tokens, models.Token{
+			ID:           id,
+			Symbol:       strings.ToLower(symbol),
+			Name:         symbol,
+			CurrentPrice: price,
+			Volume24h:    baseVolume * price,
+			UpdatedAt:    time.Now(),
+			Sources:      []string{"kraken"},
+		})
+	}
+
+	return tokens, nil
+}
+
+// Fetch historical close prices from OHLC data, stamped with the end of their
+// candle, or now for the open candle.
+// Kraken only serves the latest 720 candles of an interval.
+func (c *KrakenClient) FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error) {
+	pair, ok := c.pair(id)
+	if !ok {
+		return nil, fmt.Errorf("no Kraken symbol configured for %s", id)
+	}
+
+	interval := 1440
+	switch span := to.Sub(from); {
+	case span <= 24*time.Hour:
+		interval = 5
+	case span <= 30*24*time.Hour:
+		interval = 60
+	}
+
+	endpoint := fmt.Sprintf("%s/0/public/OHLC?pair=%s&interval=%d&since=%d", c.BaseURL, pair, interval, from.Unix())
+
+	// Each candle is [time, open, high, low, close, vwap, volume, count]
+	var candles [][]json.RawMessage
+	if err := c.get(ctx, endpoint, &candles); err != nil {
+		return nil, fmt.Errorf("failed to fetch OHLC %s: %w", pair, err)
+	}
+
+	now := time.Now()
+	points := make([]models.PriceHistory, 0, len(candles))
+	for _, candle := range candles {
+		var unix int64
+		var closePrice string
+		if len(candle) < 5 || json.Unmarshal(candle[0], &unix) != nil || json.Unmarshal(candle[4], &closePrice) != nil {
+			return nil, fmt.Errorf("unexpected OHLC format")
+		}
+		price, err := strconv.ParseFloat(closePrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OHLC price: %w", err)
+		}
+
+		ts := minTime(time.Unix(unix, 0).Add(time.Duration(interval)*time.Minute), now).UTC()
+		if ts.Before(from) || ts.After(to) {
+			continue
+		}
+		points = append(points, models.PriceHistory{TokenID: id, Price: price, Timestamp: ts})
+	}
+
+	return points, nil
+}
+
+// get fetches a Kraken endpoint returning a single pair and decodes that pair's entry
+func (c *KrakenClient) get(ctx context.Context, endpoint string, out interface{}) error {
+	var resp krakenResponse
+	if err := c.requester.getJSON(ctx, endpoint, &resp); err != nil {
+		return err
+	}
+	if len(resp.Error) > 0 {
+		return fmt.Errorf("API error: %s", strings.Join(resp.Error, ", "))
+	}
+
+	for key, raw := range resp.Result {
+		if key == "last" {
+			continue
+		}
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("empty result")
+}
+
+// pair returns the Kraken pair name of a token; the quote asset itself has no pair
+func (c *KrakenClient) pair(id string) (string, bool) {
+	symbol, ok := c.Symbols[id]
+	if !ok || strings.EqualFold(symbol, c.QuoteAsset) {
+		return "", false
+	}
+
+	base := strings.ToUpper(symbol)
+	if alias, ok := krakenAssets[base]; ok {
+		base = alias
+	}
+	return base + strings.ToUpper(c.QuoteAsset), true
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"fmt"
+	"sort"
+)
+
+// DefaultWallet is used for transactions that don't name a wallet
+const DefaultWallet = "default"
+
+// balanceEpsilon absorbs floating point noise when comparing balances
+const balanceEpsilon = 1e-9
+
+// ValidateTransaction checks that a transaction is well formed on its own
+func ValidateTransaction(tx models.Transaction) error {
+	var v apierror.Validator
+	v.Check(tx.TokenID != "", "token_id", "is required")
+	v.Check(tx.Amount > 0, "amount", "must be positive")
+	v.Check(tx.Price >= 0, "price", "must be non-negative")
+	v.Check(tx.Fee >= 0, "fee", "must be non-negative")
+
+	switch tx.Type {
+	case models.TransactionBuy, models.TransactionSell, models.TransactionDeposit,
+		models.TransactionWithdraw, models.TransactionFee:
+	case models.TransactionTransfer:
+		v.Check(tx.ToWallet != "", "to_wallet", "is required for transfers")
+		v.Check(tx.ToWallet == "" || tx.ToWallet != tx.Wallet, "to_wallet", "must differ from wallet")
+	default:
+		v.Check(false, "type", "unknown transaction type '%s'", tx.Type)
+	}
+
+	return v.Err()
+}
+
+// SortTransactions orders a ledger chronologically, keeping insertion order for ties
+func SortTransactions(transactions []models.Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Timestamp.Before(transactions[j].Timestamp)
+	})
+}
+
+// CurrentHoldings returns what a user holds now. Like AmountsAsOf, it prefers
+// the ledger, replayed into positions at their average cost, and falls back to
+// the stored holdings of users without transactions.
+func CurrentHoldings(ctx context.Context, stores db.Stores, userID string) ([]models.Portfolio, error) {
+	transactions, err := stores.Transactions.GetTransactions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return stores.Portfolios.GetHoldings(ctx, userID)
+	}
+
+	SortTransactions(transactions)
+	positions, err := ReplayLedger(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := make([]models.Portfolio, 0, len(positions))
+	for _, pos := range positions {
+		holdings = append(holdings, models.Portfolio{
+			UserID:   userID,
+			TokenID:  pos.TokenID,
+			Amount:   pos.Amount,
+			BuyPrice: pos.AvgCost,
+		})
+	}
+	return holdings, nil
+}
+
+// ReplayLedger derives the current positions from a chronologically sorted ledger.
+// Cost basis is carried at average cost; disposals reduce it proportionally.
+// An error is returned when a transaction spends more than its wallet holds.
+func ReplayLedger(transactions []models.Transaction) ([]models.Position, error) {
+	positions := make(map[string]*models.Position)
+	order := make([]string, 0)
+
+	for _, tx := range transactions {
+		pos, ok := positions[tx.TokenID]
+		if !ok {
+			pos = &models.Position{TokenID: tx.TokenID, Wallets: make(map[string]float64)}
+			positions[tx.TokenID] = pos
+			order = append(order, tx.TokenID)
+		}
+
+		wallet := tx.Wallet
+		if wallet == "" {
+			wallet = DefaultWallet
+		}
+
+		switch tx.Type {
+		case models.TransactionBuy:
+			pos.Wallets[wallet] += tx.Amount
+			pos.Amount += tx.Amount
+			pos.CostBasis += tx.Amount*tx.Price + tx.Fee
+
+		case models.TransactionDeposit:
+			pos.Wallets[wallet] += tx.Amount
+			pos.Amount += tx.Amount
+			pos.CostBasis += tx.Amount * tx.Price
+
+		case models.TransactionSell, models.TransactionWithdraw, models.TransactionFee:
+			if pos.Wallets[wallet]+balanceEpsilon < tx.Amount {
+				return nil, fmt.Errorf("%s of %g %s at %s exceeds wallet '%s' balance of %g",
+					tx.Type, tx.Amount, tx.TokenID, tx.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+					wallet, pos.Wallets[wallet])
+			}
+			pos.CostBasis -= pos.CostBasis * (tx.Amount / pos.Amount)
+			pos.Wallets[wallet] -= tx.Amount
+			pos.Amount -= tx.Amount
+
+		case models.TransactionTransfer:
+			if pos.Wallets[wallet]+balanceEpsilon < tx.Amount {
+				return nil, fmt.Errorf("transfer of %g %s at %s exceeds wallet '%s' balance of %g",
+					tx.Amount, tx.TokenID, tx.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+					wallet, pos.Wallets[wallet])
+			}
+			pos.Wallets[wallet] -= tx.Amount
+			pos.Wallets[tx.ToWallet] += tx.Amount
+		}
+
+		if pos.Wallets[wallet] <= balanceEpsilon {
+			delete(pos.Wallets, wallet)
+		}
+		if pos.Amount <= balanceEpsilon {
+			pos.Amount = 0
+			pos.CostBasis = 0
+		}
+	}
+
+	result := make([]models.Position, 0, len(order))
+	for _, tokenID := range order {
+		pos := positions[tokenID]
+		if pos.Amount == 0 {
+			continue
+		}
+		pos.AvgCost = pos.CostBasis / pos.Amount
+		result = append(result, *pos)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PriceProvider is a source of token market data
+type PriceProvider interface {
+	// Name identifies the provider in logs and responses
+	Name() string
+	// FetchTopTokens returns up to limit tokens ordered by market relevance
+	FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error)
+	// FetchTokens returns the tokens with the given IDs, skipping unknown ones
+	FetchTokens(ctx context.Context, ids []string) ([]models.Token, error)
+	// FetchHistory returns the USD price points of a token between from and to, oldest first
+	FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error)
+}
+
+// NewPriceProvider builds the providers named in the configuration. Several
+// providers are wrapped in a FallbackProvider that tries them in order, or in an
+// AggregatingProvider that combines their quotes when prices.mode is "aggregate".
+func NewPriceProvider(cfg *config.Config) (PriceProvider, error) {
+	providers := make([]PriceProvider, 0, len(cfg.Prices.Providers))
+	for _, name := range cfg.Prices.Providers {
+		switch name {
+		case "coingecko":
+			providers = append(providers, NewCoinGeckoClient(cfg.CoinGecko))
+		case "binance":
+			providers = append(providers, NewBinanceClient(cfg.Binance, cfg.Prices.Symbols))
+		case "kraken":
+			providers = append(providers, NewKrakenClient(cfg.Kraken, cfg.Prices.Symbols))
+		default:
+			return nil, fmt.Errorf("unknown price provider '%s'", name)
+		}
+	}
+
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no price provider configured")
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	if cfg.Prices.Mode == "aggregate" {
+		return &AggregatingProvider{
+			Providers:    providers,
+			Method:       cfg.Prices.Consensus,
+			MaxDeviation: cfg.Prices.MaxDeviation,
+			MinSources:   cfg.Prices.MinSources,
+		}, nil
+	}
+	return &FallbackProvider{Providers: providers}, nil
+}
+
+// FallbackProvider tries each provider in order and returns the first success
+type FallbackProvider struct {
+	Providers []PriceProvider
+}
+
+func (f *FallbackProvider) Name() string {
+	return "fallback"
+}
+
+func (f *FallbackProvider) FetchTopTokens(ctx context.Context, limit int) ([]models.Token, error) {
+	return fallback(f.Providers, func(p PriceProvider) ([]models.Token, error) {
+		return p.FetchTopTokens(ctx, limit)
+	})
+}
+
+func (f *FallbackProvider) FetchTokens(ctx context.Context, ids []string) ([]models.Token, error) {
+	return fallback(f.Providers, func(p PriceProvider) ([]models.Token, error) {
+		return p.FetchTokens(ctx, ids)
+	})
+}
+
+func (f *FallbackProvider) FetchHistory(ctx context.Context, id string, from, to time.Time) ([]models.PriceHistory, error) {
+	return fallback(f.Providers, func(p PriceProvider) ([]models.PriceHistory, error) {
+		return p.FetchHistory(ctx, id, from, to)
+	})
+}
+
+func fallback[T any](providers []PriceProvider, fetch func(PriceProvider) ([]T, error)) ([]T, error) {
+	var errs []error
+	for _, p := range providers {
+		result, err := fetch(p)
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("⚠️  Price provider %s failed: %v", p.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// minTime returns the earlier of two times
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
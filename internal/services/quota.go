@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/config"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RouteClass groups API routes that share a request budget
+type RouteClass string
+
+const (
+	ClassRead  RouteClass = "read"  // market data and per-user reads
+	ClassWrite RouteClass = "write" // per-user changes
+	ClassHeavy RouteClass = "heavy" // full scans, aggregations and stream connections
+	ClassAdmin RouteClass = "admin" // token changes, syncs and backfills
+	ClassAuth  RouteClass = "auth"  // register, login and refresh
+)
+
+// RateCounter counts requests in fixed windows. The in-memory counter suits a
+// single instance; a counter in a shared store keeps limits across replicas.
+type RateCounter interface {
+	// Increment counts a request for key in the window containing now and
+	// returns the count so far and when the window ends
+	Increment(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error)
+}
+
+// Quota is the state of a client's budget after a request
+type Quota struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Allowed   bool
+}
+
+// QuotaLimiter applies the per-class budgets of the HTTP API
+type QuotaLimiter struct {
+	Counter RateCounter
+	Window  time.Duration
+	Limits  map[RouteClass]int
+}
+
+// NewQuotaLimiter builds the limiter of the configured backend; it returns
+// nil when rate limiting is disabled
+func NewQuotaLimiter(cfg config.RateLimitConfig) (*QuotaLimiter, error) {
+	var counter RateCounter
+	switch cfg.Backend {
+	case "none":
+		return nil, nil
+	case "memory":
+		counter = NewMemoryRateCounter()
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend '%s'", cfg.Backend)
+	}
+
+	return &QuotaLimiter{
+		Counter: counter,
+		Window:  cfg.Window,
+		Limits: map[RouteClass]int{
+			ClassRead:  cfg.Read,
+			ClassWrite: cfg.Write,
+			ClassHeavy: cfg.Heavy,
+			ClassAdmin: cfg.Admin,
+			ClassAuth:  cfg.Auth,
+		},
+	}, nil
+}
+
+// Allow counts a request of a client against the budget of a route class
+func (l *QuotaLimiter) Allow(ctx context.Context, class RouteClass, client string) (Quota, error) {
+	limit := l.Limits[class]
+	count, reset, err := l.Counter.Increment(ctx, string(class)+"|"+client, l.Window, time.Now())
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return Quota{
+		Limit:     limit,
+		Remaining: max(limit-count, 0),
+		Reset:     reset,
+		Allowed:   count <= limit,
+	}, nil
+}
+
+// MemoryRateCounter counts requests in process
+type MemoryRateCounter struct {
+	mu        sync.Mutex
+	windows   map[string]rateWindow
+	nextSweep time.Time
+}
+
+type rateWindow struct {
+	count int
+	end   time.Time
+}
+
+func NewMemoryRateCounter() *MemoryRateCounter {
+	return &MemoryRateCounter{windows: make(map[string]rateWindow)}
+}
+
+func (m *MemoryRateCounter) Increment(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Windows are aligned to the clock, so ended ones are dropped once per window
+	if !now.Before(m.nextSweep) {
+		for k, w := range m.windows {
+			if !now.Before(w.end) {
+				delete(m.windows, k)
+			}
+		}
+		m.nextSweep = now.Add(window)
+	}
+
+	w := m.windows[key]
+	if !now.Before(w.end) {
+		w = rateWindow{end: now.Truncate(window).Add(window)}
+	}
+	w.count++
+	m.windows[key] = w
+
+	return w.count, w.end, nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"crypto-portfolio-tracker/internal/models"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamBuffer is how many messages a subscriber may fall behind before the
+// oldest ones are dropped
+const streamBuffer = 16
+
+// StreamMessage is pushed to stream subscribers. Price messages carry the
+// updated tokens; portfolio messages also carry the user's valuation.
+type StreamMessage struct {
+	Type      string                     `json:"type"`
+	Tokens    []models.Token             `json:"tokens"`
+	Portfolio *models.PortfolioValuation `json:"portfolio,omitempty"`
+	Time      time.Time                  `json:"time"`
+}
+
+// Stream message types
+const (
+	StreamPrices    = "prices"
+	StreamPortfolio = "portfolio"
+)
+
+// StreamHub fans price updates out to subscribers. Publishing never blocks:
+// a subscriber whose buffer is full loses its oldest message, since newer
+// prices supersede it.
+type StreamHub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewStreamHub() *StreamHub {
+	return &StreamHub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the updates of a set of tokens, or of every token
+// when the set is empty
+type Subscription struct {
+	tokens  map[string]bool
+	ch      chan StreamMessage
+	dropped atomic.Int64
+}
+
+// C delivers the subscription's messages; it is closed by Unsubscribe and Close
+func (s *Subscription) C() <-chan StreamMessage {
+	return s.ch
+}
+
+// Dropped returns how many messages were discarded because the subscriber fell behind
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Subscribe registers a subscriber to tokenIDs; no IDs subscribes to all tokens
+func (h *StreamHub) Subscribe(tokenIDs []string) *Subscription {
+	sub := &Subscription{ch: make(chan StreamMessage, streamBuffer)}
+	if len(tokenIDs) > 0 {
+		sub.tokens = make(map[string]bool, len(tokenIDs))
+		for _, id := range tokenIDs {
+			sub.tokens[id] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.ch)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (h *StreamHub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Close ends every subscription, so open streams finish before shutdown
+func (h *StreamHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscribers returns the number of connected subscribers
+func (h *StreamHub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscribers)
+}
+
+// Publish sends each subscriber the updated tokens it is subscribed to
+func (h *StreamHub) Publish(tokens []models.Token) {
+	now := time.Now()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		matched := tokens
+		if sub.tokens != nil {
+			matched = make([]models.Token, 0)
+			for _, token := range tokens {
+				if sub.tokens[token.ID] {
+					matched = append(matched, token)
+				}
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		sub.send(StreamMessage{Type: StreamPrices, Tokens: matched, Time: now})
+	}
+}
+
+// send queues a message without blocking, dropping the oldest queued message
+// when the buffer is full. Only Publish sends, under the hub's read lock, and
+// Unsubscribe closes under the write lock, so sends never race with close.
+func (s *Subscription) send(msg StreamMessage) {
+	select {
+	case s.ch <- msg:
+		return
+	default:
+	}
+
+	select {
+	case <-s.ch:
+		s.dropped.Add(1)
+	default:
+	}
+
+	select {
+	case s.ch <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
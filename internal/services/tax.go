@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/costbasis"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/tax"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnreportable is returned when the ledger cannot be turned into a report,
+// e.g. a disposal exceeds its lots or a missing price has no stored history
+var ErrUnreportable = errors.New("ledger cannot be reported")
+
+// BuildTaxReport loads a user's ledger and produces the capital gains report of a year.
+// Transactions recorded without a price are valued from stored price history.
+func BuildTaxReport(ctx context.Context, stores db.Stores, userID string, year int, method costbasis.Method) (*tax.Report, error) {
+	transactions, err := stores.Transactions.GetTransactions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	SortTransactions(transactions)
+
+	lookup := func(tokenID string, at time.Time) (float64, error) {
+		point, err := stores.Prices.GetPointAt(ctx, tokenID, at)
+		if err != nil {
+			return 0, err
+		}
+		return point.Price, nil
+	}
+	if err := tax.FillMissingPrices(transactions, lookup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreportable, err)
+	}
+
+	result, err := costbasis.Calculate(transactions, method)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreportable, err)
+	}
+
+	return tax.BuildReport(userID, year, result), nil
+}
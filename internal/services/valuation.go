@@ -0,0 +1,57 @@
+package services
+
+import (
+	"crypto-portfolio-tracker/internal/models"
+	"time"
+)
+
+// ValuePortfolio marks each holding to the given prices and computes totals.
+// Holdings without a known price are reported but left out of the totals.
+func ValuePortfolio(userID string, holdings []models.Portfolio, prices map[string]float64) models.PortfolioValuation {
+	valuation := models.PortfolioValuation{
+		UserID:        userID,
+		Holdings:      make([]models.HoldingValuation, 0, len(holdings)),
+		MissingPrices: make([]string, 0),
+		Currency:      BaseCurrency,
+		ValuedAt:      time.Now(),
+	}
+
+	for _, holding := range holdings {
+		hv := models.HoldingValuation{
+			TokenID:   holding.TokenID,
+			Amount:    holding.Amount,
+			BuyPrice:  holding.BuyPrice,
+			CostBasis: holding.Amount * holding.BuyPrice,
+		}
+
+		price, ok := prices[holding.TokenID]
+		if !ok {
+			valuation.Holdings = append(valuation.Holdings, hv)
+			valuation.MissingPrices = append(valuation.MissingPrices, holding.TokenID)
+			continue
+		}
+
+		hv.PriceAvailable = true
+		hv.CurrentPrice = price
+		hv.MarketValue = holding.Amount * price
+		hv.UnrealizedPnL = hv.MarketValue - hv.CostBasis
+		hv.UnrealizedPnLPct = percentChange(hv.CostBasis, hv.MarketValue)
+		valuation.Holdings = append(valuation.Holdings, hv)
+
+		valuation.TotalValue += hv.MarketValue
+		valuation.TotalCostBasis += hv.CostBasis
+	}
+
+	valuation.UnrealizedPnL = valuation.TotalValue - valuation.TotalCostBasis
+	valuation.UnrealizedPnLPct = percentChange(valuation.TotalCostBasis, valuation.TotalValue)
+
+	return valuation
+}
+
+// percentChange returns the change from base to value in percent, or 0 for a zero base
+func percentChange(base, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) / base * 100
+}
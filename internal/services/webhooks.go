@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto-portfolio-tracker/internal/apierror"
+	"crypto-portfolio-tracker/internal/config"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []string{models.WebhookEventAlertTriggered}
+
+// Headers sent with every delivery. The signature is "sha256=" followed by
+// the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the webhook secret.
+const (
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookDelivery  = "X-Webhook-Delivery"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// webhookBatchSize bounds the deliveries sent per poll
+const webhookBatchSize = 100
+
+// webhookDrainLimit bounds the response body read from a receiver
+const webhookDrainLimit = 64 << 10
+
+// errBlockedAddress is returned when a webhook resolves to an internal address
+var errBlockedAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range, also used by some cloud metadata services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// ValidateWebhook checks a webhook's URL and events, subscribing it to every
+// event when none are given and generating a secret when none is set
+func ValidateWebhook(hook *models.Webhook) error {
+	var v apierror.Validator
+	u, err := url.Parse(hook.URL)
+	valid := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	v.Check(valid, "url", "must be an absolute http or https URL")
+	v.Check(!valid || publicHost(u.Hostname()), "url", "must not point to a loopback, private or link-local address")
+
+	if len(hook.Events) == 0 {
+		hook.Events = slices.Clone(WebhookEvents)
+	}
+	for _, event := range hook.Events {
+		v.Check(slices.Contains(WebhookEvents, event), "events", "unknown webhook event '%s'", event)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if hook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate secret: %w", err)
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+
+	return nil
+}
+
+// publicHost rejects localhost and internal IP addresses. Other names are
+// checked when they are dialed, as they may resolve differently by then.
+func publicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+	return true
+}
+
+// publicIP reports whether an address may receive webhooks, so they cannot
+// reach this host, its internal network or a cloud metadata service
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// newWebhookClient returns an HTTP client that only connects to public
+// addresses. The check runs on the resolved address of every connection, so
+// DNS names pointing inside are refused too. Redirects are not followed and
+// proxies are not used, since either would hide the address actually reached.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SignPayload returns the value of the signature header for a delivery body
+func SignPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookPayload is the JSON body of a delivery
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDispatcher delivers events to users' webhooks. Notify only queues
+// deliveries; Start sends them in the background, so slow receivers never
+// hold up the caller. Every delivery is logged; failed ones stay pending and
+// are retried with exponential backoff until the retry policy is exhausted.
+type WebhookDispatcher struct {
+	Stores       db.Stores
+	Client       *http.Client
+	Retry        RetryPolicy
+	PollInterval time.Duration
+	Concurrency  int
+	wake         chan struct{}
+}
+
+func NewWebhookDispatcher(stores db.Stores, cfg config.WebhookConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Stores: stores,
+		Client: newWebhookClient(cfg.Timeout),
+		Retry: RetryPolicy{
+			MaxRetries: cfg.MaxRetries,
+			BaseDelay:  cfg.RetryBaseDelay,
+			MaxDelay:   cfg.RetryMaxDelay,
+		},
+		PollInterval: cfg.PollInterval,
+		Concurrency:  cfg.Concurrency,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start sends due deliveries every poll interval, and right away when Notify
+// queues new ones, until ctx is done
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("📮 Webhook dispatcher started (concurrency: %d)", d.Concurrency)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-ctx.Done():
+			log.Println("🛑 Webhook dispatcher stopped")
+			return
+		}
+
+		if err := d.DeliverDue(ctx); err != nil {
+			log.Printf("❌ Failed to send webhook deliveries: %v", err)
+		}
+	}
+}
+
+// Notify queues an event for every webhook of the user subscribed to it
+func (d *WebhookDispatcher) Notify(ctx context.Context, userID, event string, data interface{}) error {
+	hooks, err := d.Stores.Webhooks.GetWebhooks(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(webhookPayload{Event: event, CreatedAt: now, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	for _, hook := range hooks {
+		if !slices.Contains(hook.Events, event) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			UserID:        userID,
+			Event:         event,
+			URL:           hook.URL,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := d.Stores.Webhooks.SaveDelivery(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+
+	d.wakeUp()
+	return nil
+}
+
+// Redeliver queues the payload of a previous delivery as a new delivery
+func (d *WebhookDispatcher) Redeliver(ctx context.Context, previous models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	hook, err := d.Stores.Webhooks.GetWebhook(ctx, previous.UserID, previous.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		UserID:        hook.UserID,
+		Event:         previous.Event,
+		URL:           hook.URL,
+		Payload:       previous.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := d.Stores.Webhooks.SaveDelivery(ctx, &delivery); err != nil {
+		return nil, err
+	}
+
+	d.wakeUp()
+	return &delivery, nil
+}
+
+// wakeUp makes Start send due deliveries now, unless it is already due to run
+func (d *WebhookDispatcher) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverDue sends the pending deliveries whose next attempt is due, up to
+// Concurrency at a time, and returns once all of them have been attempted
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context) error {
+	deliveries, err := d.Stores.Webhooks.PendingDeliveries(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	slots := make(chan struct{}, max(d.Concurrency, 1))
+	for _, delivery := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery models.WebhookDelivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := d.deliverQueued(ctx, &delivery); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliverQueued attempts a queued delivery, giving up when its webhook was deleted
+func (d *WebhookDispatcher) deliverQueued(ctx context.Context, delivery *models.WebhookDelivery) error {
+	hook, err := d.Stores.Webhooks.GetWebhook(ctx, delivery.UserID, delivery.WebhookID)
+	if errors.Is(err, db.ErrNotFound) {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook deleted"
+		delivery.NextAttemptAt = nil
+		delivery.UpdatedAt = time.Now()
+		return d.Stores.Webhooks.SaveDelivery(ctx, delivery)
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.deliver(ctx, hook, delivery)
+}
+
+// deliver makes one attempt and records its outcome, scheduling a retry on failure
+func (d *WebhookDispatcher) deliver(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) error {
+	code, err := d.send(ctx, hook, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.URL = hook.URL
+	delivery.ResponseCode = code
+	delivery.UpdatedAt = now
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.Error = ""
+	case delivery.Attempts > d.Retry.MaxRetries:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = err.Error()
+		log.Printf("❌ Webhook delivery %s to %s failed after %d attempts: %v", delivery.ID, hook.URL, delivery.Attempts, err)
+	default:
+		next := now.Add(d.Retry.delay(delivery.Attempts - 1))
+		delivery.Status = models.DeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = &next
+		log.Printf("⚠️  Webhook delivery %s to %s failed, retrying at %s: %v", delivery.ID, hook.URL, next.Format(time.RFC3339), err)
+	}
+
+	return d.Stores.Webhooks.SaveDelivery(ctx, delivery)
+}
+
+// send posts the signed payload and returns the response status
+func (d *WebhookDispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crypto-portfolio-tracker-webhooks")
+	req.Header.Set(HeaderWebhookEvent, delivery.Event)
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID)
+	req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderWebhookSignature, SignPayload(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain a bounded part of the body so the connection can be reused. It is
+	// never stored, as the delivery log is readable by the webhook's owner.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookDrainLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receivedWebhook is a request seen by a webhookReceiver
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver records the deliveries it receives and answers them with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	received []receivedWebhook
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, receivedWebhook{header: req.Header.Clone(), body: body})
+	w.WriteHeader(r.status)
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+// newTestDispatcher returns a dispatcher on a memory store with a webhook of
+// alice pointing at a receiver answering with status. The test server's own
+// client is used, as the default one refuses loopback addresses.
+func newTestDispatcher(t *testing.T, status int) (*WebhookDispatcher, *models.Webhook, *webhookReceiver) {
+	receiver := &webhookReceiver{status: status}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	dispatcher := &WebhookDispatcher{
+		Stores:      db.NewMemoryStores(),
+		Client:      server.Client(),
+		Retry:       RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		Concurrency: 2,
+		wake:        make(chan struct{}, 1),
+	}
+
+	hook := &models.Webhook{
+		UserID:    "alice",
+		URL:       server.URL,
+		Events:    WebhookEvents,
+		Secret:    "secret",
+		CreatedAt: time.Now(),
+	}
+	if err := dispatcher.Stores.Webhooks.SaveWebhook(context.Background(), hook); err != nil {
+		t.Fatalf("failed to save webhook: %v", err)
+	}
+	return dispatcher, hook, receiver
+}
+
+// onlyDelivery returns alice's single delivery
+func onlyDelivery(t *testing.T, d *WebhookDispatcher) models.WebhookDelivery {
+	deliveries, err := d.Stores.Webhooks.GetDeliveries(context.Background(), "alice", 10)
+	if err != nil {
+		t.Fatalf("failed to list deliveries: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %+v", deliveries)
+	}
+	return deliveries[0]
+}
+
+func TestWebhookDispatcherSignsDeliveries(t *testing.T) {
+	ctx := context.Background()
+	d, hook, receiver := newTestDispatcher(t, http.StatusNoContent)
+
+	if err := d.Notify(ctx, "alice", models.WebhookEventAlertTriggered, map[string]string{"token_id": "bitcoin"}); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	if receiver.count() != 0 {
+		t.Fatalf("expected Notify to only queue, got %d requests", receiver.count())
+	}
+	if err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	if receiver.count() != 1 {
+		t.Fatalf("expected 1 request, got %d", receiver.count())
+	}
+	got := receiver.received[0]
+	timestamp, err := strconv.ParseInt(got.header.Get(HeaderWebhookTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %v", err)
+	}
+	if want := SignPayload(hook.Secret, timestamp, got.body); got.header.Get(HeaderWebhookSignature) != want {
+		t.Errorf("expected signature %s, got %s", want, got.header.Get(HeaderWebhookSignature))
+	}
+	if got.header.Get(HeaderWebhookEvent) != models.WebhookEventAlertTriggered {
+		t.Errorf("expected event header %s, got %s", models.WebhookEventAlertTriggered, got.header.Get(HeaderWebhookEvent))
+	}
+
+	delivery := onlyDelivery(t, d)
+	if got.header.Get(HeaderWebhookDelivery) != delivery.ID {
+		t.Errorf("expected delivery header %s, got %s", delivery.ID, got.header.Get(HeaderWebhookDelivery))
+	}
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 || delivery.ResponseCode != http.StatusNoContent {
+		t.Errorf("expected one successful attempt, got %+v", delivery)
+	}
+}
+
+func TestWebhookDispatcherRetriesServerErrors(t *testing.T) {
+	ctx := context.Background()
+	d, _, receiver := newTestDispatcher(t, http.StatusBadGateway)
+
+	if err := d.Notify(ctx, "alice", models.WebhookEventAlertTriggered, nil); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	before := time.Now()
+	if err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	delivery := onlyDelivery(t, d)
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseCode != http.StatusBadGateway {
+		t.Fatalf("expected a pending delivery after one failed attempt, got %+v", delivery)
+	}
+	if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(before.Add(d.Retry.BaseDelay/2)) {
+		t.Fatalf("expected a retry scheduled after the jittered base delay, got %v", delivery.NextAttemptAt)
+	}
+
+	// The retry is not due yet, so polling again sends nothing
+	if err := d.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if receiver.count() != 1 {
+		t.Errorf("expected 1 request before the retry is due, got %d", receiver.count())
+	}
+}
+
+func TestWebhookDispatcherRedeliversOnce(t *testing.T) {
+	ctx := context.Background()
+	d, hook, receiver := newTestDispatcher(t, http.StatusOK)
+
+	previous := models.WebhookDelivery{
+		WebhookID: hook.ID,
+		UserID:    "alice",
+		Event:     models.WebhookEventAlertTriggered,
+		URL:       hook.URL,
+		Payload:   []byte(`{"event":"alert.triggered"}`),
+		Status:    models.DeliveryFailed,
+		Attempts:  4,
+		CreatedAt: time.Now().Add(-time.Hour),
+	}
+	if err := d.Stores.Webhooks.SaveDelivery(ctx, &previous); err != nil {
+		t.Fatalf("failed to save delivery: %v", err)
+	}
+
+	delivery, err := d.Redeliver(ctx, previous)
+	if err != nil {
+		t.Fatalf("redeliver failed: %v", err)
+	}
+	if receiver.count() != 0 {
+		t.Fatalf("expected Redeliver to only queue, got %d requests", receiver.count())
+	}
+
+	for range 2 {
+		if err := d.DeliverDue(ctx); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+	if receiver.count() != 1 {
+		t.Fatalf("expected the redelivery to be sent once, got %d requests", receiver.count())
+	}
+	if got := receiver.received[0]; got.header.Get(HeaderWebhookDelivery) != delivery.ID || string(got.body) != string(previous.Payload) {
+		t.Errorf("expected delivery %s with the previous payload, got %s %s", delivery.ID, got.header.Get(HeaderWebhookDelivery), got.body)
+	}
+
+	stored, err := d.Stores.Webhooks.GetDelivery(ctx, "alice", delivery.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch redelivery: %v", err)
+	}
+	if stored.Status != models.DeliverySucceeded || stored.Attempts != 1 {
+		t.Errorf("expected one successful attempt, got %+v", stored)
+	}
+}
@@ -2,24 +2,42 @@ package services
 
 import (
 	"context"
+	"crypto-portfolio-tracker/internal/config"
 	"crypto-portfolio-tracker/internal/db"
+	"crypto-portfolio-tracker/internal/models"
+	"errors"
 	"log"
 	"time"
 )
 
 type PriceWorker struct {
-	ScyllaDB      *db.ScyllaDB
-	ElasticSearch *db.ElasticSearch
-	CoinGecko     *CoinGeckoClient
-	Interval      time.Duration
+	Stores    db.Stores
+	Provider  PriceProvider
+	Interval  time.Duration
+	TopTokens int
+	Watchlist []string
+	// Rates supplies the exchange rates used to store quotes in Currencies
+	Rates      ExchangeRateSource
+	Currencies []string
+	Alerts     *AlertEvaluator
+	// Webhooks queues deliveries of fired alerts; it sends them in its own goroutine
+	Webhooks *WebhookDispatcher
+	// Stream receives the tokens updated by each sync
+	Stream *StreamHub
 }
 
-func NewPriceWorker(scylla *db.ScyllaDB, es *db.ElasticSearch, interval time.Duration) *PriceWorker {
+func NewPriceWorker(stores db.Stores, provider PriceProvider, rates ExchangeRateSource, webhooks *WebhookDispatcher, stream *StreamHub, cfg *config.Config) *PriceWorker {
 	return &PriceWorker{
-		ScyllaDB:      scylla,
-		ElasticSearch: es,
-		CoinGecko:     NewCoinGeckoClient(),
-		Interval:      interval,
+		Stores:     stores,
+		Provider:   provider,
+		Interval:   cfg.Worker.Interval,
+		TopTokens:  cfg.Worker.TopTokens,
+		Watchlist:  cfg.Worker.Watchlist,
+		Rates:      rates,
+		Currencies: cfg.Prices.Currencies,
+		Alerts:     NewAlertEvaluator(stores),
+		Webhooks:   webhooks,
+		Stream:     stream,
 	}
 }
 
@@ -31,12 +49,12 @@ func (w *PriceWorker) Start(ctx context.Context) {
 	log.Printf("🔄 Price worker started (interval: %v)", w.Interval)
 
 	// Initial sync on startup
-	w.syncPrices()
+	w.syncPrices(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
-			w.syncPrices()
+			w.syncPrices(ctx)
 		case <-ctx.Done():
 			log.Println("🛑 Price worker stopped")
 			return
@@ -44,53 +62,120 @@ func (w *PriceWorker) Start(ctx context.Context) {
 	}
 }
 
-func (w *PriceWorker) syncPrices() {
-	log.Println("📊 Syncing prices from CoinGecko...")
+func (w *PriceWorker) syncPrices(ctx context.Context) {
+	log.Printf("📊 Syncing prices from %s...", w.Provider.Name())
 
-	tokens, err := w.CoinGecko.FetchTopTokens(10)
+	tokens, err := w.Provider.FetchTopTokens(ctx, w.TopTokens)
+	if errors.Is(err, ErrRateLimited) {
+		log.Printf("⏳ Price provider rate limit reached, retrying next cycle: %v", err)
+		return
+	}
 	if err != nil {
 		log.Printf("❌ Failed to fetch tokens: %v", err)
 		return
 	}
 
+	// Add watchlist tokens outside the top list
+	if missing := missingIDs(tokens, w.Watchlist); len(missing) > 0 {
+		extra, err := w.Provider.FetchTokens(ctx, missing)
+		if err != nil {
+			log.Printf("❌ Failed to fetch watchlist tokens: %v", err)
+		}
+		tokens = append(tokens, extra...)
+	}
+
+	rates := w.refreshRates(ctx)
+
 	successCount := 0
+	updated := make([]models.Token, 0, len(tokens))
+	prices := make(map[string]float64, len(tokens))
 	for _, token := range tokens {
-		// Update ScyllaDB
-		query := `INSERT INTO tokens (id, symbol, name, current_price, market_cap, volume_24h, updated_at) 
-                  VALUES (?, ?, ?, ?, ?, ?, ?)`
-
-		if err := w.ScyllaDB.Session.Query(query,
-			token.ID, token.Symbol, token.Name, token.CurrentPrice,
-			token.MarketCap, token.Volume24h, token.UpdatedAt).Exec(); err != nil {
-			log.Printf("❌ Failed to update %s in ScyllaDB: %v", token.ID, err)
-			continue
-		}
+		token.Quotes = Quotes(token.CurrentPrice, rates, w.Currencies)
 
-		// Update ElasticSearch
-		tokenMap := map[string]interface{}{
-			"id":            token.ID,
-			"symbol":        token.Symbol,
-			"name":          token.Name,
-			"current_price": token.CurrentPrice,
-			"market_cap":    token.MarketCap,
-			"volume_24h":    token.Volume24h,
-			"updated_at":    token.UpdatedAt,
+		// Update token store
+		if err := w.Stores.Tokens.SaveToken(ctx, token); err != nil {
+			log.Printf("❌ Failed to update %s in token store: %v", token.ID, err)
+			continue
 		}
 
-		if err := w.ElasticSearch.IndexToken(context.Background(), tokenMap); err != nil {
-			log.Printf("❌ Failed to index %s in ElasticSearch: %v", token.ID, err)
+		// Update search index
+		if err := w.Stores.Search.IndexToken(ctx, token); err != nil {
+			log.Printf("❌ Failed to index %s: %v", token.ID, err)
 			continue
 		}
 
 		// Save to price_history
-		historyQuery := `INSERT INTO price_history (token_id, timestamp, price) VALUES (?, ?, ?)`
-		if err := w.ScyllaDB.Session.Query(historyQuery,
-			token.ID, token.UpdatedAt, token.CurrentPrice).Exec(); err != nil {
+		point := models.PriceHistory{TokenID: token.ID, Price: token.CurrentPrice, Quotes: token.Quotes, Timestamp: token.UpdatedAt}
+		if err := w.Stores.Prices.SavePrice(ctx, point); err != nil {
 			log.Printf("❌ Failed to save price history for %s: %v", token.ID, err)
+		} else if err := RollupPoint(ctx, w.Stores.Candles, point); err != nil {
+			log.Printf("❌ Failed to update candles for %s: %v", token.ID, err)
 		}
 
+		updated = append(updated, token)
+		prices[token.ID] = token.CurrentPrice
 		successCount++
 	}
 
 	log.Printf("✅ Synced %d/%d tokens", successCount, len(tokens))
+
+	w.Stream.Publish(updated)
+
+	w.evaluateAlerts(ctx, prices)
+}
+
+// evaluateAlerts checks alert rules against the prices of this sync
+func (w *PriceWorker) evaluateAlerts(ctx context.Context, prices map[string]float64) {
+	fired, err := w.Alerts.Evaluate(ctx, prices, time.Now())
+	if err != nil {
+		log.Printf("❌ Failed to evaluate alerts: %v", err)
+		return
+	}
+
+	for _, event := range fired {
+		log.Printf("🔔 Alert %s of %s fired: %s", event.AlertID, event.UserID, event.Message)
+
+		if err := w.Webhooks.Notify(ctx, event.UserID, models.WebhookEventAlertTriggered, event); err != nil {
+			log.Printf("❌ Failed to notify webhooks of %s: %v", event.UserID, err)
+		}
+	}
+}
+
+// refreshRates fetches and stores the latest exchange rates. When the source
+// fails the previously stored rates are used, so quotes degrade gracefully.
+func (w *PriceWorker) refreshRates(ctx context.Context) map[string]float64 {
+	if w.Rates != nil {
+		rates, err := w.Rates.FetchExchangeRates(ctx)
+		if err == nil {
+			if err := w.Stores.FX.SaveRates(ctx, rates, time.Now()); err != nil {
+				log.Printf("❌ Failed to save exchange rates: %v", err)
+			}
+			return rates
+		}
+		log.Printf("⚠️  Failed to fetch exchange rates, using stored rates: %v", err)
+	}
+
+	rates, err := w.Stores.FX.GetRates(ctx)
+	if err != nil {
+		log.Printf("❌ Failed to load exchange rates: %v", err)
+		return map[string]float64{}
+	}
+	return rates
+}
+
+// missingIDs returns the IDs of ids that are not among tokens
+func missingIDs(tokens []models.Token, ids []string) []string {
+	have := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		have[token.ID] = true
+	}
+
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if !have[id] {
+			have[id] = true
+			missing = append(missing, id)
+		}
+	}
+	return missing
 }
@@ -0,0 +1,193 @@
+// Package tax turns realized disposals into yearly capital gains reports
+// laid out like IRS Form 8949.
+package tax
+
+import (
+	"crypto-portfolio-tracker/internal/costbasis"
+	"crypto-portfolio-tracker/internal/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Term classifies a disposal by holding period
+type Term string
+
+const (
+	ShortTerm Term = "short"
+	LongTerm  Term = "long"
+)
+
+// Row is a single disposal line of the report
+type Row struct {
+	Description  string    `json:"description"`
+	TokenID      string    `json:"token_id"`
+	Amount       float64   `json:"amount"`
+	DateAcquired time.Time `json:"date_acquired"`
+	DateSold     time.Time `json:"date_sold"`
+	Proceeds     float64   `json:"proceeds"`
+	CostBasis    float64   `json:"cost_basis"`
+	Gain         float64   `json:"gain"`
+	Term         Term      `json:"term"`
+}
+
+// Totals sums proceeds, cost basis and gain of a group of rows
+type Totals struct {
+	Proceeds  float64 `json:"proceeds"`
+	CostBasis float64 `json:"cost_basis"`
+	Gain      float64 `json:"gain"`
+}
+
+// Report is a user's capital gains report for one calendar year
+type Report struct {
+	UserID    string           `json:"user_id"`
+	Year      int              `json:"year"`
+	Method    costbasis.Method `json:"method"`
+	Rows      []Row            `json:"rows"`
+	ShortTerm Totals           `json:"short_term"`
+	LongTerm  Totals           `json:"long_term"`
+	Total     Totals           `json:"total"`
+}
+
+// PriceLookup returns the USD price of a token at a point in time
+type PriceLookup func(tokenID string, at time.Time) (float64, error)
+
+// FillMissingPrices values transactions recorded without a price using lookup,
+// so deposits and disposals entered without a quote still get a cost or proceeds
+func FillMissingPrices(transactions []models.Transaction, lookup PriceLookup) error {
+	for i, tx := range transactions {
+		if tx.Price != 0 {
+			continue
+		}
+		switch tx.Type {
+		case models.TransactionBuy, models.TransactionSell, models.TransactionDeposit:
+		default:
+			continue
+		}
+
+		price, err := lookup(tx.TokenID, tx.Timestamp)
+		if err != nil {
+			return fmt.Errorf("no price for %s at %s: %w", tx.TokenID, tx.Timestamp.Format(time.RFC3339), err)
+		}
+		transactions[i].Price = price
+	}
+
+	return nil
+}
+
+// Classify returns the holding period term of a disposal.
+// Assets held for more than one year are long term.
+func Classify(acquiredAt, disposedAt time.Time) Term {
+	if disposedAt.After(acquiredAt.AddDate(1, 0, 0)) {
+		return LongTerm
+	}
+	return ShortTerm
+}
+
+// BuildReport selects the disposals of year (UTC) from a realized gains result
+func BuildReport(userID string, year int, result *costbasis.Result) *Report {
+	report := &Report{
+		UserID: userID,
+		Year:   year,
+		Method: result.Method,
+		Rows:   make([]Row, 0),
+	}
+
+	for _, d := range result.Disposals {
+		if d.DisposedAt.UTC().Year() != year {
+			continue
+		}
+
+		row := Row{
+			Description:  fmt.Sprintf("%s %s", strconv.FormatFloat(d.Amount, 'f', -1, 64), strings.ToUpper(d.TokenID)),
+			TokenID:      d.TokenID,
+			Amount:       d.Amount,
+			DateAcquired: d.AcquiredAt,
+			DateSold:     d.DisposedAt,
+			Proceeds:     d.Proceeds,
+			CostBasis:    d.CostBasis,
+			Gain:         d.Gain,
+			Term:         Classify(d.AcquiredAt, d.DisposedAt),
+		}
+		report.Rows = append(report.Rows, row)
+
+		totals := &report.ShortTerm
+		if row.Term == LongTerm {
+			totals = &report.LongTerm
+		}
+		totals.add(row)
+		report.Total.add(row)
+	}
+
+	return report
+}
+
+func (t *Totals) add(row Row) {
+	t.Proceeds += row.Proceeds
+	t.CostBasis += row.CostBasis
+	t.Gain += row.Gain
+}
+
+// WriteCSV writes the report in Form 8949 column order, short term rows first
+func WriteCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"Term",
+		"(a) Description of property",
+		"(b) Date acquired",
+		"(c) Date sold or disposed of",
+		"(d) Proceeds",
+		"(e) Cost or other basis",
+		"(h) Gain or (loss)",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, term := range []Term{ShortTerm, LongTerm} {
+		for _, row := range report.Rows {
+			if row.Term != term {
+				continue
+			}
+			record := []string{
+				string(row.Term),
+				row.Description,
+				row.DateAcquired.UTC().Format("01/02/2006"),
+				row.DateSold.UTC().Format("01/02/2006"),
+				formatMoney(row.Proceeds),
+				formatMoney(row.CostBasis),
+				formatMoney(row.Gain),
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+	}
+
+	totals := []struct {
+		label  string
+		totals Totals
+	}{
+		{"Total short term", report.ShortTerm},
+		{"Total long term", report.LongTerm},
+		{"Total", report.Total},
+	}
+	for _, t := range totals {
+		record := []string{t.label, "", "", "", formatMoney(t.totals.Proceeds),
+			formatMoney(t.totals.CostBasis), formatMoney(t.totals.Gain)}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV totals: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}